@@ -0,0 +1,238 @@
+// Package download fetches the segments of the manifest tracks and joins
+// them into a single file per track, decrypting the AES-128 HLS segments.
+package download
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+var (
+	Debug  = false
+	Logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
+	// Workers is the number of segments of a track downloaded in parallel.
+	Workers = 4
+)
+
+// Ext returns the extension of the file the track is joined into.
+func Ext(t *manifest.Track) string {
+	if t.Init != nil {
+		if t.Kind == manifest.Audio {
+			return ".m4a"
+		}
+		return ".mp4"
+	}
+	if len(t.Segments) > 0 {
+		switch ext := strings.ToLower(path.Ext(urlPath(t.Segments[0].URL))); ext {
+		case ".ts", ".aac", ".mp4", ".m4a", ".m4v", ".vtt", ".ttml":
+			return ext
+		case ".m4s":
+			return ".mp4"
+		}
+	}
+	if t.PlaylistURL != "" {
+		return ".ts"
+	}
+	return ".mp4"
+}
+
+func urlPath(u string) string {
+	u, _, _ = strings.Cut(u, "?")
+	return u
+}
+
+// Track downloads the segments of the track and joins them into the file at
+// dst. The segments are stored in dir while downloading, the ones already
+// there from an interrupted download aren't downloaded again.
+func Track(client *http.Client, t *manifest.Track, dir, dst string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if err := manifest.LoadSegments(client, t); err != nil {
+		return err
+	}
+	segments := t.Segments
+	if t.Init != nil {
+		segments = append([]manifest.Segment{*t.Init}, segments...)
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("no segments found for the %s track %s", t.Kind, t.ID)
+	}
+
+	var key []byte
+	if t.Key != nil {
+		var err error
+		if key, err = fetch(client, manifest.Segment{URL: t.Key.URI}); err != nil {
+			return fmt.Errorf("failed to fetch the decryption key - %w", err)
+		}
+		if len(key) != 16 {
+			return fmt.Errorf("invalid AES-128 key of %d bytes", len(key))
+		}
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	segmentPath := func(i int) string { return SegmentPath(dir, i) }
+
+	jobs := make(chan int)
+	errs := make(chan error, len(segments))
+	wg := &sync.WaitGroup{}
+	for w := 0; w < max(Workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if _, err := os.Stat(segmentPath(i)); err == nil {
+					continue
+				}
+				s := segments[i]
+				data, err := fetch(client, s)
+				if err == nil && key != nil && (t.Init == nil || i > 0) {
+					data, err = decrypt(data, key, t.Key.IV, s.Sequence)
+				}
+				if err == nil {
+					err = writeFile(segmentPath(i), data)
+				}
+				if err != nil {
+					errs <- fmt.Errorf("segment %d/%d - %w", i+1, len(segments), err)
+				}
+			}
+		}()
+	}
+	for i := range segments {
+		jobs <- i
+		if Debug && (i+1)%50 == 0 {
+			Logger.Printf("%s %s: %d/%d segments", t.Kind, t.ID, i+1, len(segments))
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	return join(dst, len(segments), segmentPath)
+}
+
+// SegmentPath returns the path of the i-th segment of a track stored in dir,
+// the init segment being the first one.
+func SegmentPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d.seg", i))
+}
+
+// Count returns the number of segments of a track, its init segment
+// included. The segments must be loaded.
+func Count(t *manifest.Track) int {
+	if t.Init != nil {
+		return len(t.Segments) + 1
+	}
+	return len(t.Segments)
+}
+
+// Completed lists the segments of a track fully downloaded to dir.
+func Completed(dir string) []int {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	var done []int
+	for _, p := range paths {
+		var i int
+		if _, err := fmt.Sscanf(filepath.Base(p), "%05d.seg", &i); err == nil {
+			done = append(done, i)
+		}
+	}
+	sort.Ints(done)
+	return done
+}
+
+// join concatenates the segments into dst.
+func join(dst string, n int, segmentPath func(int) string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		f, err := os.Open(segmentPath(i))
+		if err != nil {
+			out.Close()
+			return err
+		}
+		_, err = io.Copy(out, f)
+		f.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// writeFile writes the data to a temporary file first so a segment file is
+// only there once complete.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func fetch(client *http.Client, s manifest.Segment) ([]byte, error) {
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Range != "" {
+		req.Header.Set("Range", "bytes="+s.Range)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("bad status code fetching %s: %s", s.URL, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// decrypt decrypts an AES-128 encrypted segment. Without IV, the media
+// sequence number of the segment is used.
+func decrypt(data, key, iv []byte, sequence int64) ([]byte, error) {
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment of %d bytes isn't a multiple of the block size", len(data))
+	}
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	// PKCS7 padding
+	if n := len(out); n > 0 {
+		pad := int(out[n-1])
+		if pad == 0 || pad > aes.BlockSize || pad > n || !bytes.Equal(out[n-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+			return nil, fmt.Errorf("bad padding, wrong decryption key?")
+		}
+		out = out[:n-pad]
+	}
+	return out, nil
+}
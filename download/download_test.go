@@ -0,0 +1,145 @@
+package download
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+// encrypt encrypts a segment the way the HLS packagers do, with PKCS7
+// padding and the sequence number as IV.
+func encrypt(data, key []byte, sequence int64) []byte {
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return out
+}
+
+func TestTrack(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	media := []byte("init|first|second|")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/key":
+			w.Write(key)
+		case strings.HasPrefix(r.URL.Path, "/enc/"):
+			n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/enc/"), ".ts"))
+			w.Write(encrypt([]byte(fmt.Sprintf("segment %d|", n)), key, int64(n)))
+		case r.URL.Path == "/media.mp4":
+			var start, end int
+			fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(media[start : end+1])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		track    *manifest.Track
+		expected string
+	}{
+		{"byte ranges", &manifest.Track{
+			Kind: manifest.Video,
+			Init: &manifest.Segment{URL: srv.URL + "/media.mp4", Range: "0-4"},
+			Segments: []manifest.Segment{
+				{URL: srv.URL + "/media.mp4", Range: "5-10"},
+				{URL: srv.URL + "/media.mp4", Range: "11-17"},
+			},
+		}, "init|first|second|"},
+		{"AES-128", &manifest.Track{
+			Kind: manifest.Video,
+			Key:  &manifest.Key{Method: "AES-128", URI: srv.URL + "/key"},
+			Segments: []manifest.Segment{
+				{URL: srv.URL + "/enc/7.ts", Sequence: 7},
+				{URL: srv.URL + "/enc/8.ts", Sequence: 8},
+				{URL: srv.URL + "/enc/9.ts", Sequence: 9},
+			},
+		}, "segment 7|segment 8|segment 9|"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dst := filepath.Join(dir, "track"+Ext(tt.track))
+			if err := Track(srv.Client(), tt.track, filepath.Join(dir, "segments"), dst); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.expected {
+				t.Errorf("got %q, expected %q", b, tt.expected)
+			}
+		})
+	}
+
+	missing := &manifest.Track{Segments: []manifest.Segment{{URL: srv.URL + "/missing.ts"}}}
+	if err := Track(srv.Client(), missing, t.TempDir(), filepath.Join(t.TempDir(), "missing.ts")); err == nil {
+		t.Error("expected an error for a missing segment")
+	}
+}
+
+func TestTrackResume(t *testing.T) {
+	var requested atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Add(1)
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	segments := filepath.Join(dir, "segments")
+	os.MkdirAll(segments, os.ModePerm)
+	// downloaded by a previous run
+	if err := os.WriteFile(SegmentPath(segments, 1), []byte("/2.ts"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	track := &manifest.Track{Segments: []manifest.Segment{{URL: srv.URL + "/1.ts"}, {URL: srv.URL + "/2.ts"}, {URL: srv.URL + "/3.ts"}}}
+	dst := filepath.Join(dir, "track.ts")
+	if err := Track(srv.Client(), track, segments, dst); err != nil {
+		t.Fatal(err)
+	}
+	if n := requested.Load(); n != 2 {
+		t.Errorf("expected the 2 missing segments to be downloaded, got %d requests", n)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "/1.ts/2.ts/3.ts" {
+		t.Errorf("got %q", b)
+	}
+}
+
+func TestExt(t *testing.T) {
+	tests := []struct {
+		track    manifest.Track
+		expected string
+	}{
+		{manifest.Track{Kind: manifest.Video, Init: &manifest.Segment{}}, ".mp4"},
+		{manifest.Track{Kind: manifest.Audio, Init: &manifest.Segment{}}, ".m4a"},
+		{manifest.Track{Segments: []manifest.Segment{{URL: "https://cdn.example/seg-1.ts?token=1"}}}, ".ts"},
+		{manifest.Track{PlaylistURL: "https://cdn.example/video.m3u8"}, ".ts"},
+	}
+	for _, tt := range tests {
+		if got := Ext(&tt.track); got != tt.expected {
+			t.Errorf("got %s, expected %s", got, tt.expected)
+		}
+	}
+}
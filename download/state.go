@@ -0,0 +1,92 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+// StateFile is the name of the file holding the state of a download in its
+// work directory.
+const StateFile = "state.json"
+
+// State is the progress of the download of an episode, saved in its work
+// directory so a new run resumes it instead of starting over.
+type State struct {
+	VideoID string `json:"video_id"`
+	// Output is the path of the video once downloaded.
+	Output string `json:"output"`
+	// ManifestURL is the signed manifest URL, reused while its token is
+	// valid.
+	ManifestURL string    `json:"manifest_url"`
+	ResolvedAt  time.Time `json:"resolved_at"`
+	// Tracks are the selected tracks: the video, the audio then the text
+	// tracks.
+	Tracks    []TrackState `json:"tracks"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TrackState is the progress of a selected track.
+type TrackState struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Lang string `json:"lang,omitempty"`
+	// Segments is the number of segments of the track, 0 until known. The
+	// segments already downloaded are found in the work directory, see
+	// Completed.
+	Segments int `json:"segments,omitempty"`
+}
+
+// LoadState reads the state saved in dir, nil if there is none.
+func LoadState(dir string) (*State, error) {
+	b, err := os.ReadFile(filepath.Join(dir, StateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Save writes the state to dir.
+func (s *State) Save(dir string) error {
+	s.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, StateFile), b)
+}
+
+// SetSelection records the selected tracks, forgetting the progress of the
+// previous ones.
+func (s *State) SetSelection(sel *manifest.Selection) {
+	s.Tracks = nil
+	for _, t := range sel.Tracks() {
+		s.Tracks = append(s.Tracks, TrackState{ID: t.ID, Kind: t.Kind.String(), Lang: t.Lang})
+	}
+}
+
+// Selected reports whether the recorded tracks are the ones of the selection,
+// in the same order.
+func (s *State) Selected(sel *manifest.Selection) bool {
+	tracks := sel.Tracks()
+	if len(tracks) != len(s.Tracks) {
+		return false
+	}
+	for i, t := range tracks {
+		if s.Tracks[i].ID != t.ID || s.Tracks[i].Kind != t.Kind.String() {
+			return false
+		}
+	}
+	return true
+}
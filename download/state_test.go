@@ -0,0 +1,66 @@
+package download
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+func TestState(t *testing.T) {
+	dir := t.TempDir()
+	if s, err := LoadState(dir); s != nil || err != nil {
+		t.Fatalf("expected no state, got %v, %v", s, err)
+	}
+
+	video := &manifest.Track{ID: "video=2000000", Kind: manifest.Video}
+	fra := &manifest.Track{ID: "audio_fra=96000", Kind: manifest.Audio, Lang: "fra"}
+	qad := &manifest.Track{ID: "audio_qad=96000", Kind: manifest.Audio, Lang: "qad"}
+	text := &manifest.Track{ID: "sdh", Kind: manifest.Text, Lang: "fra"}
+	m := &manifest.Manifest{Tracks: []*manifest.Track{
+		{ID: "video=400000", Kind: manifest.Video}, video, fra, qad, text,
+	}}
+
+	s := &State{VideoID: "abc-123", ManifestURL: "https://cdn.example/manifest.mpd?hdnea=exp=1"}
+	s.SetSelection(&manifest.Selection{Video: video, Audio: []*manifest.Track{qad, fra}, Text: []*manifest.Track{text}})
+	s.Tracks[0].Segments = 3
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ManifestURL != s.ManifestURL || !reflect.DeepEqual(loaded.Tracks, s.Tracks) {
+		t.Errorf("got %+v, expected %+v", loaded, s)
+	}
+	if !loaded.Selected(&manifest.Selection{Video: video, Audio: []*manifest.Track{qad, fra}, Text: []*manifest.Track{text}}) {
+		t.Error("expected the saved tracks to be selected")
+	}
+
+	// other preferences picked other tracks
+	for _, sel := range []*manifest.Selection{
+		{Video: m.Tracks[0], Audio: []*manifest.Track{qad, fra}, Text: []*manifest.Track{text}},
+		{Video: video, Audio: []*manifest.Track{fra, qad}, Text: []*manifest.Track{text}},
+		{Video: video, Audio: []*manifest.Track{qad}, Text: []*manifest.Track{text}},
+	} {
+		if loaded.Selected(sel) {
+			t.Errorf("expected %v not to match the saved tracks", sel.Tracks())
+		}
+	}
+}
+
+func TestCompleted(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"00002.seg", "00000.seg", "00001.seg.part", "state.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := Completed(dir); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("got %v, expected [0 2]", got)
+	}
+}
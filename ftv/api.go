@@ -0,0 +1,236 @@
+package ftv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+// MPDStreamInfo calls the k7 API to get the DASH stream info of a video.
+func (c *Client) MPDStreamInfo(videoID string, productID int, originURL string) (*StreamData, error) {
+
+	reqURL := fmt.Sprintf("%s/videos/%s?country_code=FR&w=955&h=537&screen_w=1680&screen_h=1050&player_version=5.71.7&domain=www.france.tv&device_type=desktop&browser=chrome&browser_version=108&os=macos&os_version=10_15_7&diffusion_mode=tunnel_first&gmt=0100&video_product_id=%d", c.K7URL, videoID, productID)
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request for %s, err: %v", reqURL, err)
+	}
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Accept-Language", "fr-FR;q=0.9,fr;q=0.8")
+	req.Header.Set("Dnt", "1")
+	req.Header.Set("Origin", c.SiteURL)
+	req.Header.Set("Referer", c.SiteURL+originURL)
+	req.Header.Set("Sec-Fetch-Dest", "empty")
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36")
+	req.Header.Set("Sec-Ch-Ua", "Chromium\";v=\"108\", \"Google Chrome\";v=\"108\"")
+	req.Header.Set("Sec-Ch-Ua-Platform", "\"macOS\"")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s, err: %v", reqURL, err)
+
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, apiError(reqURL, resp)
+	}
+
+	var stream StreamData
+	var bodyBuffer bytes.Buffer
+	tee := io.TeeReader(resp.Body, &bodyBuffer)
+
+	err = json.NewDecoder(tee).Decode(&stream)
+	if err != nil {
+		return nil, fmt.Errorf("%w - failed to parse the MPD JSON response data\nerr: %v\nbody: %s", ErrSchemaChanged, err, bodyBuffer.String())
+	}
+	if err := checkStream(&stream, reqURL); err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// HLSStreamInfo calls the player webservices API to get the HLS stream info of
+// a video.
+func (c *Client) HLSStreamInfo(videoID string) (*StreamData, error) {
+	apiURL := fmt.Sprintf("%s/v1/videos/%s?country_code=FR&w=1024&h=768&version=5.29.4&domain=www.france.tv&device_type=desktop&browser=safari&browser_version=13&os=macos&os_version=10_14_6&diffusion_mode=tunnel_first&gmt=%%2B1", c.PlayerURL, videoID)
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request for %s, err: %v", apiURL, err)
+	}
+	req.Header.Set("Origin", c.SiteURL)
+	// req.Header.Set("user-agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.1 Safari/605.1.15")
+	req.Header.Set("Referer", c.SiteURL+"/")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s, err: %v", apiURL, err)
+
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, apiError(apiURL, resp)
+	}
+
+	var bodyBuffer bytes.Buffer
+	tee := io.TeeReader(resp.Body, &bodyBuffer)
+
+	var stream StreamData
+	err = json.NewDecoder(tee).Decode(&stream)
+	if err != nil {
+		return nil, fmt.Errorf("%w - failed to parse JSON response data\nerr: %v\nbody: %s", ErrSchemaChanged, err, bodyBuffer.String())
+	}
+	if err := checkStream(&stream, apiURL); err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// checkStream makes sure the fields needed to download the video were found
+// in the API response. The other videos can still have them, the error isn't
+// ErrSchemaChanged.
+func checkStream(stream *StreamData, reqURL string) error {
+	if stream.Video.URL == "" || stream.Video.Format == "" {
+		return fmt.Errorf("%w - no video URL or format in the response of %s", ErrNoStream, reqURL)
+	}
+	return nil
+}
+
+// MPDManifestURL returns the signed URL of the DASH manifest.
+func (c *Client) MPDManifestURL(stream *StreamData) (string, error) {
+	if stream.Video.Token.Akamai == "" {
+		if Debug {
+			Logger.Println("video token not set")
+		}
+		return stream.Video.URL, nil
+	}
+	tokenURL := fmt.Sprintf("%s&url=%s", c.tokenURL(stream.Video.Token.Akamai), stream.Video.URL)
+	tokenURL = strings.Replace(tokenURL, "format=json", "format=text", 1)
+	resp, err := c.get(tokenURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the mpd token URL %s - %s", tokenURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", apiError(tokenURL, resp)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the mpd token URL %s - %s", tokenURL, err)
+	}
+	return string(b), nil
+}
+
+// HLSManifestURL returns the signed URL of the HLS manifest.
+func (c *Client) HLSManifestURL(stream *StreamData) (string, error) {
+	if stream.Video.Token.Akamai == "" {
+		return stream.Video.URL, nil
+	}
+	tokenURL := strings.Replace(c.tokenURL(stream.Video.Token.Akamai), "format=json", "format=text", 1)
+	resp, err := c.get(tokenURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the HLS token URL %s - %s", tokenURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", apiError(tokenURL, resp)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token URL %s - %s", tokenURL, err)
+	}
+	return string(b), nil
+}
+
+// ManifestURL returns the signed URL of the DASH or HLS manifest, depending
+// on the stream format.
+func (c *Client) ManifestURL(stream *StreamData) (string, error) {
+	if stream.Video.Format == "hls" {
+		return c.HLSManifestURL(stream)
+	}
+	return c.MPDManifestURL(stream)
+}
+
+// Manifest resolves the signed manifest URL of the stream and parses the
+// DASH or HLS manifest, depending on the stream format. The captions listed
+// by the API are added when the manifest has no subtitles.
+func (c *Client) Manifest(stream *StreamData) (*manifest.Manifest, error) {
+	manifestURL, err := c.ManifestURL(stream)
+	if err != nil {
+		return nil, err
+	}
+	return c.LoadManifest(stream, manifestURL)
+}
+
+// LoadManifest parses the manifest of the stream at an already signed URL,
+// see Manifest.
+func (c *Client) LoadManifest(stream *StreamData, manifestURL string) (*manifest.Manifest, error) {
+	if Debug {
+		Logger.Println("manifest URL", manifestURL)
+	}
+	m, err := manifest.Fetch(c.httpClient(), manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the %s manifest - %w", stream.Video.Format, err)
+	}
+	if len(m.TracksOf(manifest.Text)) == 0 {
+		m.Tracks = append(m.Tracks, stream.CaptionTracks()...)
+	}
+	return m, nil
+}
+
+var tokenExpiryRegexp = regexp.MustCompile(`(?:hdnea|hdnts|__token__)=(?:[^&]*[~&])?exp=(\d+)`)
+
+// TokenExpiry returns when the Akamai token of a signed URL expires, ok is
+// false when the URL has no token or its expiry can't be found.
+func TokenExpiry(signedURL string) (expiry time.Time, ok bool) {
+	if unescaped, err := url.QueryUnescape(signedURL); err == nil {
+		signedURL = unescaped
+	}
+	m := tokenExpiryRegexp.FindStringSubmatch(signedURL)
+	if m == nil {
+		return time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
+
+// CaptionTracks returns the captions listed by the API as text tracks.
+func (s *StreamData) CaptionTracks() []*manifest.Track {
+	var tracks []*manifest.Track
+	for i, c := range s.Video.Captions {
+		if c.URL == "" {
+			continue
+		}
+		lang := c.Lang
+		if lang == "" {
+			lang = "fra"
+		}
+		kind := strings.ToLower(c.Type)
+		tracks = append(tracks, &manifest.Track{
+			ID:       fmt.Sprintf("caption-%d", i),
+			Kind:     manifest.Text,
+			Lang:     lang,
+			MimeType: c.Format,
+			SDH:      strings.Contains(kind, "sdh") || strings.Contains(kind, "malentendant"),
+			Segments: []manifest.Segment{{URL: c.URL}},
+		})
+	}
+	return tracks
+}
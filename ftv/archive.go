@@ -0,0 +1,169 @@
+package ftv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ArchiveEntry is a completed download recorded in the archive.
+type ArchiveEntry struct {
+	// VideoID is the ID found in the page, see VideoData.VideoID.
+	VideoID string `json:"video_id"`
+	// MetaID is the ID returned by the API, see StreamData.Meta.ID. It's
+	// usually the same as the VideoID.
+	MetaID       string    `json:"meta_id,omitempty"`
+	ContentID    int       `json:"content_id,omitempty"`
+	Title        string    `json:"title"`
+	Path         string    `json:"path"`
+	Format       string    `json:"format"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	// Duration is the expected length of the video in seconds, and
+	// VideoTracks, AudioTracks and TextTracks the number of tracks muxed in
+	// it, to verify the file later on. 0 when unknown.
+	Duration    int `json:"duration,omitempty"`
+	VideoTracks int `json:"video_tracks,omitempty"`
+	AudioTracks int `json:"audio_tracks,omitempty"`
+	TextTracks  int `json:"text_tracks,omitempty"`
+	// Tolerance is how far off Duration the video can be, in seconds, set
+	// for the videos whose intro or credits were cut. 0 for the default
+	// tolerance.
+	Tolerance int `json:"tolerance,omitempty"`
+}
+
+// Archive keeps track of the videos already downloaded so they can be
+// skipped without calling the APIs, even after the files were renamed or
+// moved. The entries are stored as one JSON object per line and appended as
+// the downloads complete.
+type Archive struct {
+	path    string
+	mu      sync.Mutex
+	entries []ArchiveEntry
+}
+
+// OpenArchive loads the archive file at path. A missing file is treated as an
+// empty archive and gets created when the first entry is added.
+func OpenArchive(path string) (*Archive, error) {
+	a := &Archive{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry ArchiveEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid archive entry %s:%d - %w", path, line, err)
+		}
+		a.entries = append(a.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the archive %s - %w", path, err)
+	}
+	return a, nil
+}
+
+// Has returns the archived entry of the video, looked up by video ID, API ID
+// or content ID. Empty IDs are ignored.
+func (a *Archive) Has(videoID string, contentID int) (ArchiveEntry, bool) {
+	if a == nil {
+		return ArchiveEntry{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, e := range a.entries {
+		if videoID != "" && (e.VideoID == videoID || e.MetaID == videoID) {
+			return e, true
+		}
+		if contentID != 0 && e.ContentID == contentID {
+			return e, true
+		}
+	}
+	return ArchiveEntry{}, false
+}
+
+// Add records a completed download.
+func (a *Archive) Add(entry ArchiveEntry) error {
+	if a == nil {
+		return nil
+	}
+	if entry.DownloadedAt.IsZero() {
+		entry.DownloadedAt = time.Now()
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the archive %s - %w", a.path, err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write to the archive %s - %w", a.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+// Entries returns the archived downloads.
+func (a *Archive) Entries() []ArchiveEntry {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ArchiveEntry(nil), a.entries...)
+}
+
+// Remove forgets the downloads of the video, so it gets downloaded again.
+// The archive file is rewritten.
+func (a *Archive) Remove(videoID string) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var kept []ArchiveEntry
+	var buf bytes.Buffer
+	for _, e := range a.entries {
+		if e.VideoID == videoID {
+			continue
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+		kept = append(kept, e)
+	}
+	tmp := a.path + ".part"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write the archive %s - %w", a.path, err)
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return fmt.Errorf("failed to write the archive %s - %w", a.path, err)
+	}
+	a.entries = kept
+	return nil
+}
@@ -0,0 +1,78 @@
+package ftv
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+
+	a, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Has("0f3c9d2e", 4518237); ok {
+		t.Fatal("expected an empty archive")
+	}
+	err = a.Add(ArchiveEntry{VideoID: "0f3c9d2e", MetaID: "meta-0f3c9d2e", ContentID: 4518237, Title: "Les volcans", Path: "/library/Les volcans.mkv", Format: "mkv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reload from disk
+	a, err = OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		videoID   string
+		contentID int
+		expected  bool
+	}{
+		{"0f3c9d2e", 0, true},
+		{"meta-0f3c9d2e", 0, true},
+		{"", 4518237, true},
+		{"other", 1, false},
+		{"", 0, false},
+	} {
+		entry, ok := a.Has(tt.videoID, tt.contentID)
+		if ok != tt.expected {
+			t.Errorf("Has(%q, %d) = %t, expected %t", tt.videoID, tt.contentID, ok, tt.expected)
+		}
+		if ok && (entry.Path != "/library/Les volcans.mkv" || entry.DownloadedAt.IsZero()) {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+	}
+}
+
+func TestArchiveRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	a, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []ArchiveEntry{
+		{VideoID: "first", Path: "/library/first.mkv", Duration: 3130, VideoTracks: 1, AudioTracks: 2, Tolerance: 77},
+		{VideoID: "second", Path: "/library/second.mkv"},
+	} {
+		if err := a.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Remove("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := a.Entries()
+	if len(entries) != 1 || entries[0].VideoID != "first" || entries[0].Duration != 3130 || entries[0].AudioTracks != 2 || entries[0].Tolerance != 77 {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+	if _, ok := a.Has("second", 0); ok {
+		t.Error("expected the removed video to be forgotten")
+	}
+}
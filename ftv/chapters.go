@@ -0,0 +1,141 @@
+package ftv
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Chapter is a section of a video.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// timecode converts the loosely typed timecodes of the API, in seconds, to
+// a duration. Missing values are reported as not ok.
+func timecode(v interface{}) (time.Duration, bool) {
+	var seconds float64
+	switch t := v.(type) {
+	case float64:
+		seconds = t
+	case int:
+		seconds = float64(t)
+	case string:
+		var err error
+		if seconds, err = strconv.ParseFloat(t, 64); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// span returns the section starting at the timecode and lasting duration.
+func span(tc, duration interface{}) (start, end time.Duration, ok bool) {
+	start, ok = timecode(tc)
+	if !ok {
+		return 0, 0, false
+	}
+	length, ok := timecode(duration)
+	if !ok || length == 0 {
+		return 0, 0, false
+	}
+	return start, start + length, true
+}
+
+// Length returns the duration of the video, 0 if unknown.
+func (s *StreamData) Length() time.Duration {
+	return time.Duration(s.Video.Duration) * time.Second
+}
+
+// Previously returns the "previously on" recap section, if any.
+func (s *StreamData) Previously() (start, end time.Duration, ok bool) {
+	return span(s.Video.Previously.Timecode, s.Video.Previously.Duration)
+}
+
+// Intro returns the opening credits section, the one the web player offers
+// to skip.
+func (s *StreamData) Intro() (start, end time.Duration, ok bool) {
+	return span(s.Video.SkipIntro.Timecode, s.Video.SkipIntro.Duration)
+}
+
+// Credits returns the closing credits section, it lasts until the end of
+// the video. The "coming next" overlay is used when the closing credits
+// aren't flagged.
+func (s *StreamData) Credits() (start, end time.Duration, ok bool) {
+	end = s.Length()
+	start, ok = timecode(s.Video.ClosingCredits.Timecode)
+	if !ok {
+		start, ok = timecode(s.Video.ComingNext.Timecode)
+	}
+	if !ok || end == 0 || start >= end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// minChapterLength is the length under which gaps between the sections
+// aren't worth a chapter.
+const minChapterLength = time.Second
+
+// Chapters splits the video using the sections flagged by the API:
+// "Previously", "Intro", "Episode" and "Credits". Nothing is returned when
+// the video has no flagged sections or its duration is unknown.
+func (s *StreamData) Chapters() []Chapter {
+	length := s.Length()
+	if length == 0 {
+		return nil
+	}
+
+	var sections []Chapter
+	if start, end, ok := s.Previously(); ok {
+		sections = append(sections, Chapter{Title: "Previously", Start: start, End: end})
+	}
+	if start, end, ok := s.Intro(); ok {
+		sections = append(sections, Chapter{Title: "Intro", Start: start, End: end})
+	}
+	if start, end, ok := s.Credits(); ok {
+		sections = append(sections, Chapter{Title: "Credits", Start: start, End: end})
+	}
+	if len(sections) == 0 {
+		return nil
+	}
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].Start < sections[j].Start })
+
+	// the rest of the video is the episode itself
+	var chapters []Chapter
+	cursor := time.Duration(0)
+	for _, section := range sections {
+		if section.Start < cursor {
+			// overlapping sections, keep the first one
+			section.Start = cursor
+		}
+		if section.End > length {
+			section.End = length
+		}
+		if section.End-section.Start < minChapterLength {
+			continue
+		}
+		if section.Start-cursor >= minChapterLength {
+			chapters = append(chapters, Chapter{Title: "Episode", Start: cursor, End: section.Start})
+		} else if len(chapters) > 0 {
+			chapters[len(chapters)-1].End = section.Start
+		} else {
+			section.Start = 0
+		}
+		chapters = append(chapters, section)
+		cursor = section.End
+	}
+	if length-cursor >= minChapterLength {
+		chapters = append(chapters, Chapter{Title: "Episode", Start: cursor, End: length})
+	} else if len(chapters) > 0 {
+		chapters[len(chapters)-1].End = length
+	}
+	return chapters
+}
@@ -0,0 +1,79 @@
+package ftv
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func loadStreamFixture(t *testing.T, path string) *StreamData {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &StreamData{}
+	if err := json.Unmarshal(b, stream); err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+func TestChapters(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+
+	tests := []struct {
+		name     string
+		stream   *StreamData
+		expected []Chapter
+	}{
+		{
+			name:   "intro and credits",
+			stream: loadStreamFixture(t, "testdata/api/k7/0f3c9d2e-5b5a-11ee-9a3f-2cde48003fdc.json"),
+			expected: []Chapter{
+				{"Episode", 0, s(12)},
+				{"Intro", s(12), s(50)},
+				{"Episode", s(50), s(1512)},
+				{"Credits", s(1512), s(1568)},
+			},
+		},
+		{
+			name:   "credits only",
+			stream: loadStreamFixture(t, "testdata/api/player/6a1b2c3d-7e8f-4a5b-9c0d-1e2f3a4b5c6d.json"),
+			expected: []Chapter{
+				{"Episode", 0, s(3040)},
+				{"Credits", s(3040), s(3105)},
+			},
+		},
+		{
+			name:     "no sections",
+			stream:   &StreamData{},
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stream.Chapters(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+
+	// recap right at the start, the short gap before the intro is merged
+	stream := &StreamData{}
+	stream.Video.Duration = 600
+	stream.Video.Previously.Timecode = 0.0
+	stream.Video.Previously.Duration = 30.0
+	stream.Video.SkipIntro.Timecode = "30.5"
+	stream.Video.SkipIntro.Duration = 20.0
+	expected := []Chapter{
+		{"Previously", 0, 30*time.Second + 500*time.Millisecond},
+		{"Intro", 30*time.Second + 500*time.Millisecond, 50*time.Second + 500*time.Millisecond},
+		{"Episode", 50*time.Second + 500*time.Millisecond, s(600)},
+	}
+	if got := stream.Chapters(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,120 @@
+package ftv
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testPage = `<html><body><div><div class="l-column-left"><script>
+window.FTVPlayerVideos = [{"contentId":42,"videoId":"abc-123","originUrl":"/series/foo/42-episode.html","videoTitle":"S1 E3","programName":"Foo","seasonNumber":1}];
+</script></div></div></body></html>`
+
+// newFakeFranceTV starts a server standing in for the site, the k7 API and
+// the token endpoint.
+func newFakeFranceTV(t *testing.T) (*httptest.Server, *Client) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/series/foo/42-episode.html", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testPage)
+	})
+	mux.HandleFunc("/videos/abc-123", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("video_product_id"); got != "42" {
+			t.Errorf("expected the product id to be passed, got %q", got)
+		}
+		fmt.Fprintf(w, `{"video":{"format":"dash","url":"https://cdn.example/manifest.mpd","token":{"akamai":"https://hdfauth.ftven.fr/esi/TA?format=json"}},"meta":{"id":"abc-123","title":"Foo"}}`)
+	})
+	mux.HandleFunc("/esi/TA", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("format"); got != "text" {
+			t.Errorf("expected the text format to be requested, got %q", got)
+		}
+		fmt.Fprint(w, r.URL.Query().Get("url")+"?signed")
+	})
+
+	c := &Client{
+		HTTPClient: srv.Client(),
+		SiteURL:    srv.URL,
+		K7URL:      srv.URL,
+		PlayerURL:  srv.URL,
+		TokenURL:   srv.URL,
+	}
+	return srv, c
+}
+
+func TestClientAgainstFakeServer(t *testing.T) {
+	srv, c := newFakeFranceTV(t)
+
+	videos, err := c.ExtractVideoData(srv.URL + "/series/foo/42-episode.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 {
+		t.Fatalf("expected 1 video, got %d", len(videos))
+	}
+	data := videos[0]
+	if data.VideoID != "abc-123" || data.ContentID != 42 {
+		t.Fatalf("unexpected video data: %+v", data)
+	}
+
+	stream, err := c.MPDStreamInfo(data.VideoID, data.ContentID, data.OriginURL.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.Meta.Title != "Foo" {
+		t.Errorf("expected the title to be Foo, got %q", stream.Meta.Title)
+	}
+
+	manifestURL, err := c.MPDManifestURL(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "https://cdn.example/manifest.mpd?signed"; manifestURL != expected {
+		t.Errorf("expected manifest URL %s, got %s", expected, manifestURL)
+	}
+}
+
+func TestClientManifest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000,RESOLUTION=640x360\nvideo.m3u8\n")
+	}))
+	defer srv.Close()
+
+	stream := &StreamData{}
+	stream.Video.Format = "hls"
+	stream.Video.URL = srv.URL + "/master.m3u8"
+	stream.Video.Captions = []Caption{{Format: "vtt", Type: "SDH", URL: srv.URL + "/subs.vtt"}}
+	c := &Client{HTTPClient: srv.Client()}
+	m, err := c.Manifest(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tracks) != 2 {
+		t.Fatalf("expected the variant and the API captions, got %d tracks", len(m.Tracks))
+	}
+	caption := m.Tracks[1]
+	if caption.Lang != "fra" || !caption.SDH || len(caption.Segments) != 1 {
+		t.Errorf("unexpected caption track %s", caption)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected int64
+	}{
+		{"https://cdn.example/manifest.mpd?hdnea=exp=1700000000~acl=%2F*~hmac=abc", 1700000000},
+		{"https://cdn.example/manifest.m3u8?hdnts=st%3D1699990000%7Eexp%3D1700000600%7Eacl%3D%2F*%7Ehmac%3Dabc&other=1", 1700000600},
+		{"https://cdn.example/manifest.mpd?exp=1700000000", 0},
+		{"https://cdn.example/manifest.mpd", 0},
+	}
+	for _, tt := range tests {
+		expiry, ok := TokenExpiry(tt.url)
+		if ok != (tt.expected != 0) || (ok && expiry.Unix() != tt.expected) {
+			t.Errorf("%s: got %v (%t), expected %d", tt.url, expiry, ok, tt.expected)
+		}
+	}
+}
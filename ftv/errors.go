@@ -0,0 +1,112 @@
+package ftv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+// Reasons a video can't be downloaded, use errors.Is to check the errors
+// returned by the client.
+var (
+	ErrGeoBlocked      = errors.New("the video isn't available from this country")
+	ErrExpired         = errors.New("the video is no longer available")
+	ErrNotYetAvailable = errors.New("the video isn't available yet")
+	// ErrNoStream is returned when the API response of a video has no
+	// stream to download.
+	ErrNoStream = errors.New("no video stream in the API response")
+	// ErrDRMProtected is the same error as manifest.ErrProtected.
+	ErrDRMProtected = manifest.ErrProtected
+	// ErrSchemaChanged is returned when the API responses can't be
+	// understood anymore, the france.tv API likely changed.
+	ErrSchemaChanged = errors.New("unexpected API response, the france.tv API might have changed")
+)
+
+// APIError is an error response of the france.tv APIs.
+type APIError struct {
+	URL        string
+	StatusCode int
+	// Code and Message are the error details given by the API, if any.
+	Code    int
+	Message string
+	// Reason is the matching sentinel error, nil when unknown.
+	Reason error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s replied with status code %d", e.URL, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Reason != nil {
+		msg = e.Reason.Error() + " - " + msg
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error { return e.Reason }
+
+// apiError reads the error response of an API call. The body is usually a
+// JSON object with a code and a message.
+func apiError(reqURL string, resp *http.Response) error {
+	e := &APIError{URL: reqURL, StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var details struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &details) == nil {
+		e.Code, e.Message = details.Code, details.Message
+	}
+	e.Reason = errorReason(e)
+	return e
+}
+
+// errorReason guesses why the API refused to serve a video from the status
+// code, the error code and the message.
+func errorReason(e *APIError) error {
+	msg := strings.ToLower(e.Message)
+	containsAny := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(msg, w) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	// 2009 is the code returned to the requests made from outside of France
+	case e.Code == 2009, containsAny("geo", "géo", "country", "pays", "territo"):
+		return ErrGeoBlocked
+	case containsAny("drm"):
+		return ErrDRMProtected
+	case containsAny("pas encore", "not yet", "prochainement", "à venir", "upcoming"):
+		return ErrNotYetAvailable
+	case containsAny("expir", "no longer", "plus disponible", "n'est plus"):
+		return ErrExpired
+	case e.StatusCode == http.StatusForbidden:
+		return ErrGeoBlocked
+	case e.StatusCode == http.StatusNotFound, e.StatusCode == http.StatusGone:
+		return ErrExpired
+	}
+	return nil
+}
+
+// Available reports whether the video can be downloaded at the given time,
+// based on the page and API data: ErrExpired once the end date of the video
+// has passed and ErrDRMProtected when the stream is DRM protected.
+func Available(data VideoData, stream *StreamData, now time.Time) error {
+	if !data.EndDate.IsZero() && data.EndDate.Before(now) {
+		return fmt.Errorf("%w since %s", ErrExpired, data.EndDate.Format("2006-01-02"))
+	}
+	if stream.Video.Drm {
+		return ErrDRMProtected
+	}
+	return nil
+}
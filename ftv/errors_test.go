@@ -0,0 +1,78 @@
+package ftv
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamInfoErrors(t *testing.T) {
+	responses := map[string]struct {
+		status int
+		body   string
+	}{
+		"geo":       {http.StatusForbidden, `{"code":2009,"message":"Cette vidéo n'est pas disponible depuis votre pays"}`},
+		"expired":   {http.StatusNotFound, `{"code":2001,"message":"Video not found"}`},
+		"upcoming":  {http.StatusBadRequest, `{"message":"Cette vidéo n'est pas encore disponible"}`},
+		"drm":       {http.StatusBadRequest, `{"message":"DRM protected content"}`},
+		"unknown":   {http.StatusInternalServerError, `oops`},
+		"bad-json":  {http.StatusOK, `{"video":`},
+		"no-stream": {http.StatusOK, `{"meta":{"id":"no-stream"}}`},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := responses[r.URL.Path[len("/videos/"):]]
+		w.WriteHeader(res.status)
+		fmt.Fprint(w, res.body)
+	}))
+	defer srv.Close()
+	c := &Client{HTTPClient: srv.Client(), SiteURL: srv.URL, K7URL: srv.URL}
+
+	tests := []struct {
+		id       string
+		expected error
+	}{
+		{"geo", ErrGeoBlocked},
+		{"expired", ErrExpired},
+		{"upcoming", ErrNotYetAvailable},
+		{"drm", ErrDRMProtected},
+		{"unknown", nil},
+		{"bad-json", ErrSchemaChanged},
+		{"no-stream", ErrNoStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			_, err := c.MPDStreamInfo(tt.id, 0, "")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.expected != nil && !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+			if tt.expected != ErrSchemaChanged && errors.Is(err, ErrSchemaChanged) {
+				t.Errorf("expected an error specific to the video, got %v", err)
+			}
+			var apiErr *APIError
+			if tt.expected == nil && (!errors.As(err, &apiErr) || apiErr.Reason != nil || apiErr.StatusCode != http.StatusInternalServerError) {
+				t.Errorf("expected an APIError without reason, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stream := &StreamData{}
+	if err := Available(VideoData{EndDate: now.AddDate(0, 0, 1)}, stream, now); err != nil {
+		t.Errorf("expected the video to be available, got %v", err)
+	}
+	if err := Available(VideoData{EndDate: now.AddDate(0, 0, -1)}, stream, now); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+	stream.Video.Drm = true
+	if err := Available(VideoData{}, stream, now); !errors.Is(err, ErrDRMProtected) {
+		t.Errorf("expected ErrDRMProtected, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package ftv
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// playerDataExtractor is one of the strategies used to find the player data
+// in a page. The site layout changes often so we try a few in order, from the
+// most to the least specific.
+type playerDataExtractor struct {
+	name string
+	// extract returns the videos found in the page, nothing if the strategy
+	// doesn't apply or an error if the data was found but couldn't be used.
+	extract func(doc *goquery.Document) ([]VideoData, error)
+}
+
+var playerDataExtractors = []playerDataExtractor{
+	{name: "column script", extract: extractFromColumnScript},
+	{name: "FTVPlayerVideos script", extract: extractFromAnyScript},
+	{name: "__NEXT_DATA__", extract: extractFromNextData},
+	{name: "data attributes", extract: extractFromDataAttributes},
+	{name: "JSON-LD", extract: extractFromJSONLD},
+}
+
+// extractPlayerData runs the extractors in order and returns the videos
+// found by the first one to succeed. An empty list of videos is reported as
+// ErrNoPlayerData.
+func extractPlayerData(doc *goquery.Document) ([]VideoData, error) {
+	var lastErr error = ErrNoPlayerData
+	for _, e := range playerDataExtractors {
+		data, err := e.extract(doc)
+		if err != nil {
+			if Debug {
+				Logger.Printf("%s extractor failed: %v\n", e.name, err)
+			}
+			if errorRank(err) > errorRank(lastErr) {
+				lastErr = err
+			}
+			continue
+		}
+		if len(data) > 0 {
+			if Debug {
+				Logger.Printf("Player data found using the %s extractor\n", e.name)
+			}
+			if data[0].Description == "" {
+				data[0].Description = pageDescription(doc)
+			}
+			return data, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// pageDescription returns the summary of the page's main video.
+func pageDescription(doc *goquery.Document) string {
+	for _, selector := range []string{`meta[property="og:description"]`, `meta[name="description"]`} {
+		if content, ok := doc.Find(selector).First().Attr("content"); ok && strings.TrimSpace(content) != "" {
+			return strings.TrimSpace(content)
+		}
+	}
+	return ""
+}
+
+// errorRank is used to report the most specific error when all the
+// extractors fail.
+func errorRank(err error) int {
+	switch {
+	case errors.Is(err, ErrBadPlayerJSONData):
+		return 3
+	case errors.Is(err, ErrMissingPayerJSONData):
+		return 2
+	case errors.Is(err, ErrNoPlayerData):
+		return 1
+	}
+	return 0
+}
+
+// extractFromColumnScript is the historical location of the player data:
+// a script in the left column assigning window.FTVPlayerVideos.
+func extractFromColumnScript(doc *goquery.Document) ([]VideoData, error) {
+	scriptText := doc.Find("div > div.l-column-left > script").Text()
+	scriptText = strings.TrimSpace(scriptText)
+	if !strings.HasPrefix(scriptText, "window.FTVPlayerVideos") && !strings.HasPrefix(scriptText, "let FTVPlayerVideos") {
+		return nil, nil
+	}
+	return parsePlayerVideosScript(scriptText)
+}
+
+// extractFromAnyScript looks for FTVPlayerVideos in all the scripts of the
+// page.
+func extractFromAnyScript(doc *goquery.Document) ([]VideoData, error) {
+	var data []VideoData
+	var err error
+	doc.Find("script").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		scriptText := s.Text()
+		if !strings.Contains(scriptText, "FTVPlayerVideos") {
+			return true
+		}
+		data, err = parsePlayerVideosScript(scriptText)
+		return err != nil || len(data) == 0
+	})
+	return data, err
+}
+
+// parsePlayerVideosScript decodes the JSON array assigned to FTVPlayerVideos.
+func parsePlayerVideosScript(scriptText string) ([]VideoData, error) {
+	idx := strings.Index(scriptText, "FTVPlayerVideos")
+	startIDX := strings.Index(scriptText[idx:], "[")
+	if startIDX < 0 {
+		return nil, ErrMissingPayerJSONData
+	}
+	var data []VideoData
+	// the decoder stops at the end of the array and ignores the rest of the
+	// script
+	if err := json.NewDecoder(strings.NewReader(scriptText[idx+startIDX:])).Decode(&data); err != nil {
+		if Debug {
+			Logger.Printf("Failed to parse video json data:\n%s\nerr: %v", scriptText, err)
+		}
+		return nil, ErrBadPlayerJSONData
+	}
+	return data, nil
+}
+
+// extractFromNextData looks for the player data in the JSON blobs used by
+// Next.js style pages.
+func extractFromNextData(doc *goquery.Document) ([]VideoData, error) {
+	var data []VideoData
+	var err error
+	doc.Find(`script#__NEXT_DATA__, script[type="application/json"]`).Each(func(i int, s *goquery.Selection) {
+		var blob interface{}
+		if jErr := json.Unmarshal([]byte(s.Text()), &blob); jErr != nil {
+			return
+		}
+		walkJSON(blob, func(obj map[string]interface{}) {
+			if _, ok := obj["videoId"].(string); !ok {
+				return
+			}
+			b, jErr := json.Marshal(obj)
+			if jErr != nil {
+				return
+			}
+			var v VideoData
+			if jErr := json.Unmarshal(b, &v); jErr != nil {
+				err = ErrBadPlayerJSONData
+				return
+			}
+			data = appendVideo(data, v)
+		})
+	})
+	if len(data) > 0 {
+		return data, nil
+	}
+	return nil, err
+}
+
+// extractFromDataAttributes reads the player data from the data-* attributes
+// of the player element.
+func extractFromDataAttributes(doc *goquery.Document) ([]VideoData, error) {
+	var data []VideoData
+	doc.Find("[data-video-id]").Each(func(i int, s *goquery.Selection) {
+		v := VideoData{
+			VideoID:     s.AttrOr("data-video-id", ""),
+			VideoTitle:  s.AttrOr("data-video-title", ""),
+			ProgramName: s.AttrOr("data-program-name", ""),
+		}
+		if v.VideoID == "" {
+			return
+		}
+		v.ContentID, _ = strconv.Atoi(s.AttrOr("data-content-id", ""))
+		v.SeasonNumber, _ = strconv.Atoi(s.AttrOr("data-season-number", ""))
+		if originURL, ok := s.Attr("data-origin-url"); ok {
+			v.OriginURL = originURL
+		}
+		data = appendVideo(data, v)
+	})
+	return data, nil
+}
+
+// contentIDFromPath extracts the content ID from france.tv page paths such as
+// /series/foo/4518237-les-volcans.html
+var contentIDFromPath = regexp.MustCompile(`/(\d+)-[^/]*\.html$`)
+
+// extractFromJSONLD builds the player data from the schema.org VideoObject
+// meant for search engines. It's the least detailed source.
+func extractFromJSONLD(doc *goquery.Document) ([]VideoData, error) {
+	var data []VideoData
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var blob interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &blob); err != nil {
+			return
+		}
+		walkJSON(blob, func(obj map[string]interface{}) {
+			if t, _ := obj["@type"].(string); t != "VideoObject" {
+				return
+			}
+			v := VideoData{}
+			v.VideoTitle, _ = obj["name"].(string)
+			v.Description, _ = obj["description"].(string)
+			v.VideoID, _ = obj["identifier"].(string)
+			if embedURL, ok := obj["embedUrl"].(string); ok && v.VideoID == "" {
+				if u, err := url.Parse(embedURL); err == nil {
+					for _, key := range []string{"id", "videoId", "video_id"} {
+						if id := u.Query().Get(key); id != "" {
+							v.VideoID = id
+							break
+						}
+					}
+				}
+			}
+			if v.VideoID == "" {
+				return
+			}
+			if pageURL, ok := obj["url"].(string); ok {
+				if u, err := url.Parse(pageURL); err == nil {
+					v.OriginURL = u.Path
+					if m := contentIDFromPath.FindStringSubmatch(u.Path); m != nil {
+						v.ContentID, _ = strconv.Atoi(m[1])
+					}
+				}
+			}
+			data = appendVideo(data, v)
+		})
+	})
+	return data, nil
+}
+
+// walkJSON calls fn on every object found in a decoded JSON value.
+func walkJSON(v interface{}, fn func(map[string]interface{})) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		fn(t)
+		// sorted so the videos are always listed in the same order
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkJSON(t[k], fn)
+		}
+	case []interface{}:
+		for _, child := range t {
+			walkJSON(child, fn)
+		}
+	}
+}
+
+// appendVideo appends v unless a video with the same ID is already listed.
+func appendVideo(data []VideoData, v VideoData) []VideoData {
+	for _, d := range data {
+		if d.VideoID == v.VideoID {
+			return data
+		}
+	}
+	return append(data, v)
+}
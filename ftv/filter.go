@@ -0,0 +1,179 @@
+package ftv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects the episodes of a collection without asking the user.
+// The zero value selects everything.
+type Filter struct {
+	// Include keeps the episodes whose title matches.
+	Include *regexp.Regexp
+	// Exclude drops the episodes whose title matches.
+	Exclude  *regexp.Regexp
+	Seasons  Ranges
+	Episodes Ranges
+	// After and Before limit the broadcast dates, zero values are ignored.
+	After  time.Time
+	Before time.Time
+	// Latest keeps the N first matching episodes of the listing, france.tv
+	// lists them from the newest to the oldest.
+	Latest int
+}
+
+// IsZero returns true if the filter doesn't filter anything out.
+func (f *Filter) IsZero() bool {
+	return f == nil || (f.Include == nil && f.Exclude == nil &&
+		len(f.Seasons) == 0 && len(f.Episodes) == 0 &&
+		f.After.IsZero() && f.Before.IsZero() && f.Latest <= 0)
+}
+
+// Cards returns the cards matching the title, season and episode filters.
+// Season and episode numbers are read from the card titles, cards without
+// them are kept and checked again with MatchStream once the stream info is
+// available.
+func (f *Filter) Cards(cards []Card) []Card {
+	if f.IsZero() {
+		return cards
+	}
+	var selected []Card
+	for _, card := range cards {
+		if f.Latest > 0 && len(selected) >= f.Latest {
+			break
+		}
+		if !f.matchTitle(card.Title) {
+			continue
+		}
+		if season, episode, ok := ParseEpisodeNumbers(card.Title); ok {
+			if !f.Seasons.Contains(season) || !f.Episodes.Contains(episode) {
+				continue
+			}
+		}
+		selected = append(selected, card)
+	}
+	return selected
+}
+
+// MatchStream checks the filters against the metadata returned by the API.
+func (f *Filter) MatchStream(data VideoData, stream *StreamData) bool {
+	if f.IsZero() {
+		return true
+	}
+	title := strings.Join([]string{stream.Meta.Title, stream.Meta.PreTitle, stream.Meta.AdditionalTitle}, " ")
+	if !f.matchTitle(title) {
+		return false
+	}
+
+	season, episode, ok := ParseEpisodeNumbers(stream.Meta.PreTitle)
+	if !ok {
+		season, episode, ok = ParseEpisodeNumbers(data.VideoTitle)
+	}
+	if !ok {
+		season = data.SeasonNumber
+		if season == 0 {
+			season = stream.Markers.Npaw.Season
+		}
+	}
+	if len(f.Seasons) > 0 && (season == 0 || !f.Seasons.Contains(season)) {
+		return false
+	}
+	if len(f.Episodes) > 0 && (!ok || !f.Episodes.Contains(episode)) {
+		return false
+	}
+
+	broadcastedAt := stream.Meta.BroadcastedAt
+	if !f.After.IsZero() && broadcastedAt.Before(f.After) {
+		return false
+	}
+	if !f.Before.IsZero() && !broadcastedAt.Before(f.Before) {
+		return false
+	}
+	return true
+}
+
+func (f *Filter) matchTitle(title string) bool {
+	if f.Include != nil && !f.Include.MatchString(title) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(title) {
+		return false
+	}
+	return true
+}
+
+var episodeNumbersRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bS(\d+)\s*E(\d+)\b`),
+	regexp.MustCompile(`(?i)\bsaison\s*(\d+)\D+?[ée]pisode\s*(\d+)`),
+}
+
+// ParseEpisodeNumbers finds the season and episode numbers in titles such as
+// "S1 E12 - Les volcans" or "Saison 1 - Épisode 12".
+func ParseEpisodeNumbers(title string) (season, episode int, ok bool) {
+	for _, re := range episodeNumbersRegexps {
+		if m := re.FindStringSubmatch(title); m != nil {
+			season, _ = strconv.Atoi(m[1])
+			episode, _ = strconv.Atoi(m[2])
+			return season, episode, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Range is an inclusive range of numbers, a Max of Unbounded means no upper
+// bound.
+type Range struct {
+	Min, Max int
+}
+
+// Unbounded is the Max of the open ranges such as "8-".
+const Unbounded = -1
+
+// Ranges is a list of ranges, an empty list contains everything.
+type Ranges []Range
+
+// Contains returns true if n is in one of the ranges.
+func (r Ranges) Contains(n int) bool {
+	if len(r) == 0 {
+		return true
+	}
+	for _, rg := range r {
+		if n >= rg.Min && (rg.Max == Unbounded || n <= rg.Max) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRanges parses comma separated numbers and ranges such as "1-3,5,8-".
+func ParseRanges(s string) (Ranges, error) {
+	var ranges Ranges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		minStr, maxStr, isRange := strings.Cut(part, "-")
+		min, err := strconv.Atoi(strings.TrimSpace(minStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q - %w", part, err)
+		}
+		rg := Range{Min: min, Max: min}
+		if isRange {
+			rg.Max = Unbounded
+			if maxStr = strings.TrimSpace(maxStr); maxStr != "" {
+				if rg.Max, err = strconv.Atoi(maxStr); err != nil {
+					return nil, fmt.Errorf("invalid range %q - %w", part, err)
+				}
+				if rg.Max < rg.Min {
+					return nil, fmt.Errorf("invalid range %q, %d is lower than %d", part, rg.Max, rg.Min)
+				}
+			}
+		}
+		ranges = append(ranges, rg)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,142 @@
+package ftv
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseRanges(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Ranges
+		wantErr  bool
+	}{
+		{in: "", expected: nil},
+		{in: "3", expected: Ranges{{3, 3}}},
+		{in: "0", expected: Ranges{{0, 0}}},
+		{in: "1-3, 5,8-", expected: Ranges{{1, 3}, {5, 5}, {8, Unbounded}}},
+		{in: "0-", expected: Ranges{{0, Unbounded}}},
+		{in: "3-1", wantErr: true},
+		{in: "a-2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRanges(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRanges(%q) error = %v, wantErr %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ParseRanges(%q) = %v, expected %v", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestRangesContains(t *testing.T) {
+	tests := []struct {
+		in       string
+		n        int
+		expected bool
+	}{
+		{"", 3, true},
+		{"0", 0, true},
+		{"0", 1, false},
+		{"0", 12, false},
+		{"1-3", 3, true},
+		{"1-3", 4, false},
+		{"8-", 100, true},
+		{"8-", 7, false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRanges(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Contains(tt.n); got != tt.expected {
+			t.Errorf("ParseRanges(%q).Contains(%d) = %t, expected %t", tt.in, tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestParseEpisodeNumbers(t *testing.T) {
+	tests := []struct {
+		title           string
+		season, episode int
+		ok              bool
+	}{
+		{"S1 E12 - Les volcans", 1, 12, true},
+		{"s02e03", 2, 3, true},
+		{"Saison 3 - Épisode 4 : L'homme au complet marron", 3, 4, true},
+		{"Japon, un nouveau monde sauvage", 0, 0, false},
+	}
+	for _, tt := range tests {
+		season, episode, ok := ParseEpisodeNumbers(tt.title)
+		if season != tt.season || episode != tt.episode || ok != tt.ok {
+			t.Errorf("ParseEpisodeNumbers(%q) = %d, %d, %t, expected %d, %d, %t", tt.title, season, episode, ok, tt.season, tt.episode, tt.ok)
+		}
+	}
+}
+
+func TestFilterCards(t *testing.T) {
+	cards := []Card{
+		{URL: "/e14", Title: "S1 E14 - La lune"},
+		{URL: "/e13", Title: "S1 E13 - Les abeilles"},
+		{URL: "/bonus", Title: "Bonus - Les coulisses"},
+		{URL: "/s2e1", Title: "S2 E1 - Le chocolat"},
+	}
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []string
+	}{
+		{"no filter", nil, []string{"/e14", "/e13", "/bonus", "/s2e1"}},
+		{"include", &Filter{Include: regexp.MustCompile(`(?i)lune|chocolat`)}, []string{"/e14", "/s2e1"}},
+		{"exclude", &Filter{Exclude: regexp.MustCompile(`Bonus`)}, []string{"/e14", "/e13", "/s2e1"}},
+		// cards without numbers are kept to be checked against the stream info
+		{"season", &Filter{Seasons: Ranges{{2, 2}}}, []string{"/bonus", "/s2e1"}},
+		{"episodes", &Filter{Episodes: Ranges{{13, Unbounded}}}, []string{"/e14", "/e13", "/bonus"}},
+		{"latest", &Filter{Latest: 2, Exclude: regexp.MustCompile(`E14`)}, []string{"/e13", "/bonus"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range tt.filter.Cards(cards) {
+				got = append(got, c.URL)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterMatchStream(t *testing.T) {
+	stream := &StreamData{}
+	stream.Meta.Title = "C'est toujours pas sorcier"
+	stream.Meta.PreTitle = "S1 E12"
+	stream.Meta.AdditionalTitle = "Les volcans"
+	stream.Meta.BroadcastedAt = time.Date(2023, 9, 30, 9, 15, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected bool
+	}{
+		{"no filter", &Filter{}, true},
+		{"title", &Filter{Include: regexp.MustCompile(`volcans`)}, true},
+		{"season", &Filter{Seasons: Ranges{{2, Unbounded}}}, false},
+		{"episode", &Filter{Episodes: Ranges{{10, 12}}}, true},
+		{"after", &Filter{After: day("2023-10-01")}, false},
+		{"before", &Filter{Before: day("2023-10-01")}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.MatchStream(VideoData{}, stream); got != tt.expected {
+			t.Errorf("%s: got %t, expected %t", tt.name, got, tt.expected)
+		}
+	}
+}
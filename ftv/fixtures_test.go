@@ -0,0 +1,179 @@
+package ftv
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test ./ftv -update rewrites the golden files from the fixtures.
+var update = flag.Bool("update", false, "update the golden files")
+
+// newFixtureServer serves the recorded pages and API responses found in
+// testdata:
+//
+//	/pages/<name>.html             testdata/pages/<name>.html
+//	/collections/<name>/?page=N    testdata/collections/<name>.page-N.html
+//	/videos/<id>                   testdata/api/k7/<id>.json
+//	/v1/videos/<id>                testdata/api/player/<id>.json
+//
+// Collection pages past the recorded ones are served empty, like france.tv
+// does at the end of the pagination.
+func newFixtureServer(t *testing.T) (*httptest.Server, *Client) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.Handle("/pages/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/collections/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/collections/"), "/")
+		path := filepath.Join("testdata", "collections", name+".html")
+		if page := r.URL.Query().Get("page"); page != "" {
+			path = filepath.Join("testdata", "collections", name+".page-"+page+".html")
+		}
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join("testdata", "collections", "empty.html")
+		}
+		http.ServeFile(w, r, path)
+	})
+	mux.HandleFunc("/videos/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "api", "k7", filepath.Base(r.URL.Path)+".json"))
+	})
+	mux.HandleFunc("/v1/videos/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "api", "player", filepath.Base(r.URL.Path)+".json"))
+	})
+
+	c := &Client{
+		HTTPClient: srv.Client(),
+		SiteURL:    srv.URL,
+		K7URL:      srv.URL,
+		PlayerURL:  srv.URL,
+		TokenURL:   srv.URL,
+	}
+	return srv, c
+}
+
+// fixtures returns the names of the files matching the pattern, stripped of
+// their extension.
+func fixtures(t *testing.T, pattern string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no fixtures found matching %s", pattern)
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	return names
+}
+
+// assertGolden compares the JSON encoding of v with the golden file, or
+// writes it when running with -update.
+func assertGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", "golden", name+".json")
+
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file, run the tests with -update - %v", err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("%s doesn't match the golden file %s\ngot:\n%s\nexpected:\n%s", name, path, got, expected)
+	}
+}
+
+// errorResult is stored in the golden files when an error is expected.
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+func TestExtractVideoDataFixtures(t *testing.T) {
+	srv, c := newFixtureServer(t)
+	for _, name := range fixtures(t, "testdata/pages/*.html") {
+		t.Run(name, func(t *testing.T) {
+			data, err := c.ExtractVideoData(fmt.Sprintf("%s/pages/%s.html", srv.URL, name))
+			if err != nil {
+				assertGolden(t, "pages."+name, errorResult{Error: err.Error()})
+				return
+			}
+			assertGolden(t, "pages."+name, data)
+		})
+	}
+}
+
+func TestCollectionFixtures(t *testing.T) {
+	srv, c := newFixtureServer(t)
+	for _, name := range fixtures(t, "testdata/collections/*.html") {
+		if strings.Contains(name, ".page-") {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			cards, err := c.Collection(fmt.Sprintf("%s/collections/%s", srv.URL, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the server URL changes with every run
+			for i := range cards {
+				cards[i].URL = strings.TrimPrefix(cards[i].URL, srv.URL)
+			}
+			assertGolden(t, "collections."+name, cards)
+		})
+	}
+}
+
+// streamResult is the part of the decoded API responses checked against the
+// golden files.
+type streamResult struct {
+	Meta  interface{} `json:"meta"`
+	Video interface{} `json:"video"`
+}
+
+func TestMPDStreamInfoFixtures(t *testing.T) {
+	_, c := newFixtureServer(t)
+	for _, id := range fixtures(t, "testdata/api/k7/*.json") {
+		t.Run(id, func(t *testing.T) {
+			stream, err := c.MPDStreamInfo(id, 0, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, "api.k7."+id, streamResult{Meta: stream.Meta, Video: stream.Video})
+		})
+	}
+}
+
+func TestHLSStreamInfoFixtures(t *testing.T) {
+	_, c := newFixtureServer(t)
+	for _, id := range fixtures(t, "testdata/api/player/*.json") {
+		t.Run(id, func(t *testing.T) {
+			stream, err := c.HLSStreamInfo(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, "api.player."+id, streamResult{Meta: stream.Meta, Video: stream.Video})
+		})
+	}
+}
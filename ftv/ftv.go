@@ -0,0 +1,86 @@
+// Package ftv resolves france.tv pages into downloadable stream manifests.
+//
+// The Client finds the player data embedded in episode pages, queries the
+// france.tv video APIs for the stream information, resolves the signed
+// manifest URLs and lists the episodes of collection pages.
+package ftv
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/mattetti/francetv/retry"
+)
+
+var Debug = false
+var Logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
+
+var ErrNoPlayerData = errors.New("no playerData found")
+var ErrMissingPayerJSONData = errors.New("no JSON data found")
+var ErrBadPlayerJSONData = errors.New("Bad JSON data found")
+
+const (
+	DefaultSiteURL   = "https://www.france.tv"
+	DefaultK7URL     = "https://k7.ftven.fr"
+	DefaultPlayerURL = "https://player.webservices.francetelevisions.fr"
+)
+
+// Client talks to france.tv and its video APIs.
+// All the fields can be changed to point the client at a stand-in server.
+type Client struct {
+	// HTTPClient is used for all requests, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// SiteURL is the base URL of the france.tv website.
+	SiteURL string
+	// K7URL is the base URL of the API serving the DASH stream info.
+	K7URL string
+	// PlayerURL is the base URL of the player webservices serving the HLS
+	// stream info.
+	PlayerURL string
+	// TokenURL overrides the scheme and host of the Akamai token URLs
+	// returned by the APIs. Left empty, the returned URLs are used as is.
+	TokenURL string
+}
+
+// NewClient returns a client talking to the france.tv production hosts. Its
+// requests are retried according to retry.DefaultPolicy.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: retry.NewClient(nil, retry.DefaultPolicy),
+		SiteURL:    DefaultSiteURL,
+		K7URL:      DefaultK7URL,
+		PlayerURL:  DefaultPlayerURL,
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) get(u string) (*http.Response, error) {
+	return c.httpClient().Get(u)
+}
+
+// tokenURL rewrites the host of the token URL if TokenURL is set.
+func (c *Client) tokenURL(rawURL string) string {
+	if c.TokenURL == "" {
+		return rawURL
+	}
+	base, err := url.Parse(c.TokenURL)
+	if err != nil {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	return u.String()
+}
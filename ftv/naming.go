@@ -0,0 +1,219 @@
+package ftv
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NamePresets are the output templates available by name.
+var NamePresets = map[string]string{
+	// default is the historical naming: "Show - S1E3 - Title.mkv"
+	"default":  "{program} - {pretitle} - {title}.{ext}",
+	"plex":     "{program}/Season {season:02}/{program} - s{season:02}e{episode:02} - {title}.{ext}",
+	"jellyfin": "{program}/Season {season:02}/{program} S{season:02}E{episode:02} - {title}.{ext}",
+}
+
+// NameFields are the values available in the output templates.
+type NameFields struct {
+	Program  string
+	Season   int
+	Episode  int
+	Title    string
+	PreTitle string
+	Date     time.Time
+	VideoID  string
+	Channel  string
+	Ext      string
+}
+
+// NewNameFields collects the template values from the page and API data.
+func NewNameFields(data VideoData, stream *StreamData, ext string) NameFields {
+	f := NameFields{
+		Program:  stream.Meta.Title,
+		Title:    stream.Meta.AdditionalTitle,
+		PreTitle: stream.Meta.PreTitle,
+		Date:     stream.Meta.BroadcastedAt,
+		VideoID:  data.VideoID,
+		Channel:  stream.Markers.Npaw.Channel,
+		Ext:      ext,
+	}
+	if f.Program == "" {
+		f.Program = data.ProgramName
+	}
+	if f.PreTitle == "" {
+		f.PreTitle = data.VideoTitle
+	}
+	if f.Channel == "" {
+		f.Channel = stream.Markers.Piano.Channel
+	}
+
+	var ok bool
+	if f.Season, f.Episode, ok = ParseEpisodeNumbers(stream.Meta.PreTitle); !ok {
+		f.Season, f.Episode, _ = ParseEpisodeNumbers(data.VideoTitle)
+	}
+	if f.Season == 0 {
+		f.Season = data.SeasonNumber
+	}
+	if f.Season == 0 {
+		f.Season = stream.Markers.Npaw.Season
+	}
+	return f
+}
+
+// DisplayTitle returns the title of the episode, falling back to the
+// pre-title and then to the name of the show for the standalone videos.
+func (f NameFields) DisplayTitle() string {
+	switch {
+	case f.Title != "":
+		return f.Title
+	case f.PreTitle != "":
+		return f.PreTitle
+	}
+	return f.Program
+}
+
+var placeholderRegexp = regexp.MustCompile(`\{(\w+)(?::(0\d+))?\}`)
+
+// value returns the value of a placeholder, numbers can be zero padded.
+func (f NameFields) value(name, padding string) (string, error) {
+	number := func(n int) string {
+		if padding == "" {
+			return strconv.Itoa(n)
+		}
+		width, _ := strconv.Atoi(padding)
+		return fmt.Sprintf("%0*d", width, n)
+	}
+
+	switch name {
+	case "program":
+		return f.Program, nil
+	case "season":
+		return number(f.Season), nil
+	case "episode":
+		return number(f.Episode), nil
+	case "title":
+		return f.Title, nil
+	case "pretitle":
+		// historically stripped of its spaces: "S1 E3" becomes "S1E3"
+		return strings.ReplaceAll(f.PreTitle, " ", ""), nil
+	case "date":
+		if f.Date.IsZero() {
+			return "", nil
+		}
+		return f.Date.Format("2006-01-02"), nil
+	case "id":
+		return f.VideoID, nil
+	case "channel":
+		return f.Channel, nil
+	case "ext":
+		return f.Ext, nil
+	}
+	return "", fmt.Errorf("unknown placeholder {%s}", name)
+}
+
+// ValidateTemplate checks that a template only uses known placeholders.
+func ValidateTemplate(template string) error {
+	if preset, ok := NamePresets[template]; ok {
+		template = preset
+	}
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(template, -1) {
+		if _, err := (NameFields{}).value(m[1], m[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderName renders an output template into a relative, slash separated,
+// file path. A template can also be the name of one of the NamePresets.
+//
+// The placeholders are {program}, {season}, {episode}, {title}, {pretitle},
+// {date}, {id}, {channel} and {ext}. Numbers can be zero padded: {season:02}.
+// The extension is added if the template doesn't end with it and the
+// separators left dangling by empty values are cleaned up. Each path
+// component is made safe with the sanitizer and truncated to its maximum
+// length, the file name keeping its extension.
+func RenderName(template string, fields NameFields, s Sanitizer) (string, error) {
+	if preset, ok := NamePresets[template]; ok {
+		template = preset
+	}
+
+	var err error
+	rendered := placeholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		m := placeholderRegexp.FindStringSubmatch(placeholder)
+		v, vErr := fields.value(m[1], m[2])
+		if vErr != nil && err == nil {
+			err = vErr
+		}
+		// a value can't introduce new directories
+		return strings.ReplaceAll(v, "/", "-")
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ext := ""
+	if fields.Ext != "" {
+		ext = "." + fields.Ext
+		rendered = strings.TrimSuffix(rendered, ext)
+	}
+
+	var components []string
+	for _, c := range strings.Split(rendered, "/") {
+		if c = cleanNameComponent(s.Name(c)); c != "" {
+			components = append(components, c)
+		}
+	}
+	if len(components) == 0 {
+		return "", fmt.Errorf("template %q rendered an empty name", template)
+	}
+	last := len(components) - 1
+	for i := range components[:last] {
+		components[i] = cleanNameComponent(s.Truncate(components[i], ""))
+	}
+	components[last] = s.Truncate(components[last]+ext, ext)
+	return path.Join(components...), nil
+}
+
+// ShowDir returns the relative, slash separated, directory grouping the
+// episodes by show and season: "Show/Season 01". The season directory is
+// omitted when the season is unknown.
+func ShowDir(fields NameFields, s Sanitizer) string {
+	program := cleanNameComponent(s.Truncate(cleanNameComponent(s.Name(fields.Program)), ""))
+	if program == "" {
+		return ""
+	}
+	if fields.Season == 0 {
+		return program
+	}
+	return path.Join(program, fmt.Sprintf("Season %02d", fields.Season))
+}
+
+var repeatedSeparatorsRegexp = regexp.MustCompile(`\s+-(\s*-)*\s+`)
+
+// cleanNameComponent collapses the separators left by empty values:
+// "Foo - S1E3 - " becomes "Foo - S1E3". The components left as "." or ".."
+// are dropped.
+func cleanNameComponent(c string) string {
+	c = repeatedSeparatorsRegexp.ReplaceAllString(c, " - ")
+	if c = strings.Trim(c, " -_"); isDotName(c) {
+		return ""
+	}
+	return c
+}
+
+// JoinOutput joins a name rendered by RenderName or ShowDir to the output
+// directory and makes sure the path doesn't escape it.
+func JoinOutput(dir, name string) (string, error) {
+	full := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(filepath.Clean(dir), full)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the output name %q points outside of %s", name, dir)
+	}
+	return full, nil
+}
@@ -0,0 +1,131 @@
+package ftv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderName(t *testing.T) {
+	fields := NameFields{
+		Program:  "C'est toujours pas sorcier",
+		Season:   1,
+		Episode:  3,
+		Title:    "Les volcans",
+		PreTitle: "S1 E3",
+		Date:     time.Date(2023, 9, 30, 9, 15, 0, 0, time.UTC),
+		VideoID:  "0f3c9d2e",
+		Channel:  "France 4",
+		Ext:      "mkv",
+	}
+	noTitle := fields
+	noTitle.Title = ""
+
+	tests := []struct {
+		template string
+		fields   NameFields
+		expected string
+	}{
+		{"default", fields, "C'est toujours pas sorcier - S1E3 - Les volcans.mkv"},
+		{"default", noTitle, "C'est toujours pas sorcier - S1E3.mkv"},
+		{"plex", fields, "C'est toujours pas sorcier/Season 01/C'est toujours pas sorcier - s01e03 - Les volcans.mkv"},
+		{"jellyfin", noTitle, "C'est toujours pas sorcier/Season 01/C'est toujours pas sorcier S01E03.mkv"},
+		{"{channel}/{date} {title} [{id}]", fields, "France 4/2023-09-30 Les volcans [0f3c9d2e].mkv"},
+		{"{channel}/{title}", NameFields{Title: "Les volcans", Ext: "mp4"}, "Les volcans.mp4"},
+		{"{program}/{title}", NameFields{Program: "AC/DC", Title: "Live", Ext: "mkv"}, "AC-DC/Live.mkv"},
+		// the API values can't point to the parent directory
+		{"{program}/{title}", NameFields{Program: "..", Title: "x", Ext: "mkv"}, "x.mkv"},
+		{"plex", NameFields{Program: "..", Season: 1, Episode: 2, Title: "x", Ext: "mkv"}, "Season 01/.. - s01e02 - x.mkv"},
+		{"{program}/{title}", NameFields{Program: ". - ", Title: "x", Ext: "mkv"}, "x.mkv"},
+	}
+	for _, tt := range tests {
+		got, err := RenderName(tt.template, tt.fields, Sanitizer{})
+		if err != nil {
+			t.Errorf("RenderName(%q) failed: %v", tt.template, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("RenderName(%q) = %q, expected %q", tt.template, got, tt.expected)
+		}
+	}
+
+	if got, err := RenderName("{program}/{title}", NameFields{Program: "..", Title: ".."}, Sanitizer{}); err == nil {
+		t.Errorf("expected an error for a name made of dots, got %q", got)
+	}
+	if _, err := RenderName("{show}", fields, Sanitizer{}); err == nil {
+		t.Error("expected an error for an unknown placeholder")
+	}
+	if err := ValidateTemplate("{program}/{nope}"); err == nil {
+		t.Error("expected the template to be invalid")
+	}
+}
+
+func TestRenderNameTruncates(t *testing.T) {
+	fields := NameFields{Title: strings.Repeat("é", 40), Ext: "mkv"}
+	got, err := RenderName("{title}", fields, Sanitizer{MaxLength: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Repeat("é", 10) + ".mkv"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestRenderNameTruncatesDirectories(t *testing.T) {
+	fields := NameFields{Program: strings.Repeat("a", 40), Season: 1, Title: "x", Ext: "mkv"}
+	got, err := RenderName("{program}/Season {season:02}/{title}", fields, Sanitizer{MaxLength: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Repeat("a", 20) + "/Season 01/x.mkv"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if got, expected := ShowDir(fields, Sanitizer{MaxLength: 20}), strings.Repeat("a", 20)+"/Season 01"; got != expected {
+		t.Errorf("ShowDir got %q, expected %q", got, expected)
+	}
+}
+
+func TestJoinOutput(t *testing.T) {
+	dir := filepath.Join("videos", "tv")
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Show/Season 01/x.mkv", filepath.Join(dir, "Show", "Season 01", "x.mkv")},
+		{"Show/../x.mkv", filepath.Join(dir, "x.mkv")},
+		{"../x.mkv", ""},
+		{"Show/../../x.mkv", ""},
+		{"..", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := JoinOutput(dir, tt.name)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("JoinOutput(%q) = %q, expected an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("JoinOutput(%q) = %q, %v, expected %q", tt.name, got, err, tt.expected)
+		}
+	}
+}
+
+func TestShowDir(t *testing.T) {
+	tests := []struct {
+		fields   NameFields
+		expected string
+	}{
+		{NameFields{Program: "C'est toujours pas sorcier", Season: 1}, "C'est toujours pas sorcier/Season 01"},
+		{NameFields{Program: "Questions/Réponses"}, "Questions-Réponses"},
+		{NameFields{Season: 2}, ""},
+		{NameFields{Program: "..", Season: 2}, ""},
+	}
+	for _, tt := range tests {
+		if got := ShowDir(tt.fields, Sanitizer{}); got != tt.expected {
+			t.Errorf("ShowDir(%+v) = %q, expected %q", tt.fields, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ftv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ExtractVideoData extracts the video data used to then call the API
+// the data is stored in the HTML page as a JSON object. This function extracts
+// the JSON object and returns all the videos it lists, the main video comes
+// first, followed by the other players of the page (bonus clips, sign
+// language or audio description versions...).
+// Note that the script location changes often, the lookup tries a few
+// strategies in order, see playerDataExtractors.
+func (c *Client) ExtractVideoData(pageURL string) ([]VideoData, error) {
+	res, err := c.get(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("Bad Status code fetching %s: %d %s", pageURL, res.StatusCode, res.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the page: %s", err)
+	}
+
+	return extractPlayerData(doc)
+}
+
+// Card is an episode listed on a collection page.
+type Card struct {
+	URL   string
+	Title string
+}
+
+// Collection returns the episodes listed on a collection page such as a
+// show's "toutes-les-videos" page, following the pagination.
+func (c *Client) Collection(collectionURL string) ([]Card, error) {
+	return c.collection(collectionURL, nil)
+}
+
+func (c *Client) collection(pageURL string, cards []Card) ([]Card, error) {
+	res, err := c.get(pageURL)
+	if err != nil {
+		return cards, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return cards, fmt.Errorf("status code error fetching %s: %d %s", pageURL, res.StatusCode, res.Status)
+	}
+
+	// Load the HTML document
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return cards, fmt.Errorf("failed to parse the page %s: %w", pageURL, err)
+	}
+	count := 0
+
+	doc.Find("a.c-card-16x9").Each(func(i int, s *goquery.Selection) {
+		count++
+		href, _ := s.Attr("href")
+		cards = append(cards, Card{
+			URL:   c.SiteURL + href,
+			Title: s.Find(".c-card-16x9__subtitle").First().Text(),
+		})
+	})
+
+	if count == 0 {
+		return cards, nil
+	}
+
+	if !strings.Contains(pageURL, "?page") {
+		if Debug {
+			Logger.Println("Checking pagination")
+		}
+		return c.collection(pageURL+"/?page=1", cards)
+	}
+	idx := strings.LastIndex(pageURL, "page=")
+	if idx > 0 {
+		currentPage, err := strconv.Atoi(pageURL[idx+5:])
+		if err != nil {
+			return cards, fmt.Errorf("couldn't get the next page - %w", err)
+		}
+		nextURL := pageURL[:idx+5] + strconv.Itoa(currentPage+1)
+		return c.collection(nextURL, cards)
+	}
+
+	return cards, nil
+}
@@ -0,0 +1,175 @@
+package ftv
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SanitizeProfile defines which characters are allowed in file names.
+type SanitizeProfile int
+
+const (
+	// SanitizePOSIX only replaces the path separator and control characters.
+	SanitizePOSIX SanitizeProfile = iota
+	// SanitizeWindows also replaces the characters Windows and SMB shares
+	// reject, trims the trailing dots and spaces and avoids reserved names.
+	SanitizeWindows
+	// SanitizeASCII applies the Windows rules and transliterates the
+	// accented letters, dropping any other non ASCII character.
+	SanitizeASCII
+)
+
+func (p SanitizeProfile) String() string {
+	switch p {
+	case SanitizePOSIX:
+		return "posix"
+	case SanitizeWindows:
+		return "windows"
+	case SanitizeASCII:
+		return "ascii"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSanitizeProfile returns the profile matching the name.
+func ParseSanitizeProfile(name string) (SanitizeProfile, error) {
+	switch strings.ToLower(name) {
+	case "posix":
+		return SanitizePOSIX, nil
+	case "windows":
+		return SanitizeWindows, nil
+	case "ascii":
+		return SanitizeASCII, nil
+	}
+	return 0, fmt.Errorf("unknown sanitize profile %q, expected posix, windows or ascii", name)
+}
+
+// DefaultSanitizeMaxLength leaves room under the usual 255 bytes limit for
+// the suffixes added to the temporary and subtitle files.
+const DefaultSanitizeMaxLength = 200
+
+// Sanitizer makes names safe to use as file names.
+type Sanitizer struct {
+	Profile SanitizeProfile
+	// MaxLength is the maximum length, in bytes, of a file name including
+	// its extension. 0 means no limit.
+	MaxLength int
+}
+
+// DefaultSanitizer uses the rules of the current platform.
+func DefaultSanitizer() Sanitizer {
+	s := Sanitizer{Profile: SanitizePOSIX, MaxLength: DefaultSanitizeMaxLength}
+	if runtime.GOOS == "windows" {
+		s.Profile = SanitizeWindows
+	}
+	return s
+}
+
+var windowsReplacer = strings.NewReplacer(
+	`<`, "-",
+	`>`, "-",
+	`:`, " -",
+	`"`, "'",
+	`\`, "-",
+	`|`, "-",
+	`?`, "",
+	`*`, "",
+)
+
+var asciiReplacer = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ã", "a", "å", "a",
+	"À", "A", "Â", "A", "Ä", "A", "Á", "A", "Ã", "A", "Å", "A",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+	"î", "i", "ï", "i", "í", "i", "ì", "i",
+	"Î", "I", "Ï", "I", "Í", "I", "Ì", "I",
+	"ô", "o", "ö", "o", "ó", "o", "ò", "o", "õ", "o", "ø", "o",
+	"Ô", "O", "Ö", "O", "Ó", "O", "Ò", "O", "Õ", "O", "Ø", "O",
+	"ù", "u", "û", "u", "ü", "u", "ú", "u",
+	"Ù", "U", "Û", "U", "Ü", "U", "Ú", "U",
+	"ÿ", "y", "ý", "y", "Ÿ", "Y", "Ý", "Y",
+	"ç", "c", "Ç", "C", "ñ", "n", "Ñ", "N",
+	"œ", "oe", "Œ", "OE", "æ", "ae", "Æ", "AE", "ß", "ss",
+	"’", "'", "‘", "'", "«", "'", "»", "'", "“", "'", "”", "'",
+	"–", "-", "—", "-", "…", "...", " ", " ",
+)
+
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Name sanitizes a single path component. The separators are replaced so a
+// title can't create unexpected directories.
+func (s Sanitizer) Name(name string) string {
+	name = strings.ReplaceAll(name, "/", "-")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	if s.Profile >= SanitizeWindows {
+		name = windowsReplacer.Replace(name)
+	}
+	if s.Profile == SanitizeASCII {
+		name = asciiReplacer.Replace(name)
+		name = strings.Map(func(r rune) rune {
+			if r > unicode.MaxASCII {
+				return -1
+			}
+			return r
+		}, name)
+	}
+
+	name = strings.Join(strings.Fields(name), " ")
+	if s.Profile >= SanitizeWindows {
+		name = strings.TrimRight(name, ". ")
+		base := strings.ToUpper(name)
+		if idx := strings.IndexByte(base, '.'); idx >= 0 {
+			base = base[:idx]
+		}
+		if windowsReservedNames[base] {
+			name = "_" + name
+		}
+	}
+	// "." and ".." would point to the current or the parent directory
+	if isDotName(name) {
+		name = strings.Repeat("_", len(name))
+	}
+	return name
+}
+
+func isDotName(name string) bool {
+	return name == "." || name == ".."
+}
+
+// Truncate shortens a file name to MaxLength bytes, keeping its extension
+// and not cutting in the middle of a character.
+func (s Sanitizer) Truncate(name, ext string) string {
+	if s.MaxLength <= 0 || len(name) <= s.MaxLength {
+		return name
+	}
+	base := strings.TrimSuffix(name, ext)
+	max := s.MaxLength - len(ext)
+	if max <= 0 {
+		return name[:s.MaxLength]
+	}
+	for len(base) > max {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	base = strings.TrimRight(base, " -_")
+	if s.Profile >= SanitizeWindows {
+		base = strings.TrimRight(base, ". ")
+	}
+	return base + ext
+}
@@ -0,0 +1,59 @@
+package ftv
+
+import "testing"
+
+func TestSanitizerName(t *testing.T) {
+	tests := []struct {
+		profile  SanitizeProfile
+		in       string
+		expected string
+	}{
+		{SanitizePOSIX, "Questions/Réponses: qui ?", "Questions-Réponses: qui ?"},
+		{SanitizePOSIX, "Tab\there", "Tabhere"},
+		{SanitizePOSIX, "..", "__"},
+		{SanitizePOSIX, ".", "_"},
+		{SanitizePOSIX, "...", "..."},
+		{SanitizeASCII, "..", ""},
+		{SanitizeWindows, "Questions/Réponses: qui ?", "Questions-Réponses - qui"},
+		{SanitizeWindows, `Le "grand" soir...`, "Le 'grand' soir"},
+		{SanitizeWindows, "con", "_con"},
+		{SanitizeWindows, "Nul.mkv", "_Nul.mkv"},
+		{SanitizeASCII, "Épisode 3 : L'œuf de Pâques à Noël", "Episode 3 - L'oeuf de Paques a Noel"},
+		{SanitizeASCII, "« Ça va » ★", "' Ca va '"},
+	}
+	for _, tt := range tests {
+		if got := (Sanitizer{Profile: tt.profile}).Name(tt.in); got != tt.expected {
+			t.Errorf("%s: Name(%q) = %q, expected %q", tt.profile, tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestSanitizerTruncate(t *testing.T) {
+	tests := []struct {
+		max      int
+		in, ext  string
+		expected string
+	}{
+		{0, "Les volcans.mkv", ".mkv", "Les volcans.mkv"},
+		{20, "Les volcans.mkv", ".mkv", "Les volcans.mkv"},
+		{12, "Les volcans.mkv", ".mkv", "Les volc.mkv"},
+		// the separators left at the end are trimmed
+		{8, "Les volcans.mkv", ".mkv", "Les.mkv"},
+		// "é" is 2 bytes long and can't be cut in half
+		{10, "Épopée.mp4", ".mp4", "Épop.mp4"},
+	}
+	for _, tt := range tests {
+		if got := (Sanitizer{MaxLength: tt.max}).Truncate(tt.in, tt.ext); got != tt.expected {
+			t.Errorf("Truncate(%q) with %d = %q, expected %q", tt.in, tt.max, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSanitizeProfile(t *testing.T) {
+	if p, err := ParseSanitizeProfile("Windows"); err != nil || p != SanitizeWindows {
+		t.Errorf("got %v, %v", p, err)
+	}
+	if _, err := ParseSanitizeProfile("fat32"); err == nil {
+		t.Error("expected an error")
+	}
+}
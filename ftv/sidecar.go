@@ -0,0 +1,81 @@
+package ftv
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+)
+
+// EpisodeNFO is the Kodi episode metadata file, also read by Jellyfin, Emby
+// and Plex (with the XBMCnfoTVImporter agent).
+// See https://kodi.wiki/view/NFO_files/Episodes
+type EpisodeNFO struct {
+	XMLName   xml.Name `xml:"episodedetails" json:"-"`
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle,omitempty"`
+	Season    int      `xml:"season,omitempty"`
+	Episode   int      `xml:"episode,omitempty"`
+	Plot      string   `xml:"plot,omitempty"`
+	Aired     string   `xml:"aired,omitempty"`
+	Runtime   int      `xml:"runtime,omitempty"`
+	Studio    string   `xml:"studio,omitempty"`
+	Genre     string   `xml:"genre,omitempty"`
+	Thumb     string   `xml:"thumb,omitempty"`
+	UniqueID  struct {
+		Type    string `xml:"type,attr"`
+		Default bool   `xml:"default,attr"`
+		ID      string `xml:",chardata"`
+	} `xml:"uniqueid"`
+}
+
+// NewEpisodeNFO builds the NFO metadata of a video.
+func NewEpisodeNFO(data VideoData, stream *StreamData) EpisodeNFO {
+	fields := NewNameFields(data, stream, "")
+	nfo := EpisodeNFO{
+		Title:     fields.DisplayTitle(),
+		ShowTitle: fields.Program,
+		Season:    fields.Season,
+		Episode:   fields.Episode,
+		Plot:      data.Description,
+		Runtime:   stream.Video.Duration / 60,
+		Studio:    fields.Channel,
+		Genre:     stream.Genre(),
+		Thumb:     stream.Meta.ImageURL,
+	}
+	if !fields.Date.IsZero() {
+		nfo.Aired = fields.Date.Format("2006-01-02")
+	}
+	nfo.UniqueID.Type = "francetv"
+	nfo.UniqueID.Default = true
+	nfo.UniqueID.ID = data.VideoID
+	if nfo.UniqueID.ID == "" {
+		nfo.UniqueID.ID = stream.Meta.ID
+	}
+	return nfo
+}
+
+// WriteNFO writes the Kodi/Jellyfin .nfo file of a video to path.
+func WriteNFO(path string, data VideoData, stream *StreamData) error {
+	b, err := xml.MarshalIndent(NewEpisodeNFO(data, stream), "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append([]byte(xml.Header), b...)
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// InfoJSON is the raw metadata of a video, as returned by the page and the
+// API, written next to the video for archival purposes.
+type InfoJSON struct {
+	Page   VideoData   `json:"page"`
+	Stream *StreamData `json:"stream"`
+}
+
+// WriteInfoJSON writes the .info.json file of a video to path.
+func WriteInfoJSON(path string, data VideoData, stream *StreamData) error {
+	b, err := json.MarshalIndent(InfoJSON{Page: data, Stream: stream}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,48 @@
+package ftv
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSidecars(t *testing.T) {
+	stream := loadStreamFixture(t, "testdata/api/player/6a1b2c3d-7e8f-4a5b-9c0d-1e2f3a4b5c6d.json")
+	data := VideoData{VideoID: stream.Meta.ID, ContentID: 5166237, Description: "Une saison au Japon."}
+
+	assertGolden(t, "nfo.6a1b2c3d-7e8f-4a5b-9c0d-1e2f3a4b5c6d", NewEpisodeNFO(data, stream))
+
+	dir := t.TempDir()
+	nfoPath := filepath.Join(dir, "video.nfo")
+	if err := WriteNFO(nfoPath, data, stream); err != nil {
+		t.Fatal(err)
+	}
+	var nfo EpisodeNFO
+	b, err := os.ReadFile(nfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(b, &nfo); err != nil {
+		t.Fatalf("invalid nfo file - %v\n%s", err, b)
+	}
+	if nfo.Title != "Japon, un nouveau monde sauvage" || nfo.Aired != "2024-03-12" || nfo.Genre != "Documentaire" {
+		t.Errorf("unexpected nfo %+v", nfo)
+	}
+
+	infoPath := filepath.Join(dir, "video.info.json")
+	if err := WriteInfoJSON(infoPath, data, stream); err != nil {
+		t.Fatal(err)
+	}
+	var info InfoJSON
+	if b, err = os.ReadFile(infoPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Page.Description != data.Description || info.Stream.Meta.ImageURL != stream.Meta.ImageURL {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
@@ -0,0 +1,136 @@
+package ftv
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+)
+
+// Thumbnail is a preview of the video, a region of one of the spritesheet
+// images.
+type Thumbnail struct {
+	// Image is the index of the image in Spritesheet.Images.
+	Image      int
+	X, Y, W, H int
+	Start, End time.Duration
+}
+
+// Spritesheet returns the spritesheet with the largest thumbnails, if any.
+func (s *StreamData) Spritesheet() (Spritesheet, bool) {
+	var best Spritesheet
+	found := false
+	for _, sheet := range s.Video.Spritesheets {
+		if len(sheet.Images) == 0 || sheet.Width <= 0 || sheet.Height <= 0 || sheet.Lines <= 0 || sheet.Columns <= 0 || sheet.Interval <= 0 {
+			continue
+		}
+		if !found || sheet.Width > best.Width {
+			best, found = sheet, true
+		}
+	}
+	return best, found
+}
+
+// Thumbnails lists the thumbnails of the spritesheet, in order. The
+// thumbnails past the end of the video, padding the last image, are left out
+// when the length of the video is known.
+func (sheet Spritesheet) Thumbnails(length time.Duration) []Thumbnail {
+	interval := time.Duration(sheet.Interval * float64(time.Second))
+	if interval <= 0 {
+		return nil
+	}
+	var thumbs []Thumbnail
+	for i := range sheet.Images {
+		for n := 0; n < sheet.Lines*sheet.Columns; n++ {
+			start := time.Duration(len(thumbs)) * interval
+			if length > 0 && start >= length {
+				return thumbs
+			}
+			end := start + interval
+			if length > 0 && end > length {
+				end = length
+			}
+			thumbs = append(thumbs, Thumbnail{
+				Image: i,
+				X:     n % sheet.Columns * sheet.Width,
+				Y:     n / sheet.Columns * sheet.Height,
+				W:     sheet.Width,
+				H:     sheet.Height,
+				Start: start,
+				End:   end,
+			})
+		}
+	}
+	return thumbs
+}
+
+// WriteThumbnailsVTT writes a WebVTT thumbnails track, each cue points to
+// the region of the spritesheet image to show using a media fragment:
+// "sprite_0.jpg#xywh=160,0,160,90". imageNames are the names of the
+// spritesheet images, relative to the track.
+func WriteThumbnailsVTT(w io.Writer, thumbs []Thumbnail, imageNames []string) error {
+	if _, err := io.WriteString(w, "WEBVTT\n"); err != nil {
+		return err
+	}
+	for _, t := range thumbs {
+		if t.Image >= len(imageNames) {
+			return fmt.Errorf("missing the name of the spritesheet image %d", t.Image)
+		}
+		_, err := fmt.Fprintf(w, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(t.Start), vttTimestamp(t.End), imageNames[t.Image], t.X, t.Y, t.W, t.H)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vttTimestamp formats a duration as HH:MM:SS.mmm
+func vttTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// subImager is implemented by the images decoded by the standard library.
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// SliceThumbnails cuts the thumbnails of a spritesheet image out of it.
+func SliceThumbnails(img image.Image, thumbs []Thumbnail) []image.Image {
+	var frames []image.Image
+	for _, t := range thumbs {
+		r := image.Rect(t.X, t.Y, t.X+t.W, t.Y+t.H).Add(img.Bounds().Min)
+		if !r.In(img.Bounds()) {
+			continue
+		}
+		if si, ok := img.(subImager); ok {
+			frames = append(frames, si.SubImage(r))
+			continue
+		}
+		frame := image.NewRGBA(image.Rect(0, 0, t.W, t.H))
+		draw.Draw(frame, frame.Bounds(), img, r.Min, draw.Src)
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// ContactSheet lays the frames out in a grid of the given number of
+// columns.
+func ContactSheet(frames []image.Image, columns int) image.Image {
+	if len(frames) == 0 || columns <= 0 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+	w, h := frames[0].Bounds().Dx(), frames[0].Bounds().Dy()
+	lines := (len(frames) + columns - 1) / columns
+	if len(frames) < columns {
+		columns = len(frames)
+	}
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*w, lines*h))
+	for i, frame := range frames {
+		at := image.Pt(i%columns*w, i/columns*h)
+		draw.Draw(sheet, image.Rectangle{Min: at, Max: at.Add(image.Pt(w, h))}, frame, frame.Bounds().Min, draw.Src)
+	}
+	return sheet
+}
@@ -0,0 +1,85 @@
+package ftv
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThumbnails(t *testing.T) {
+	stream := loadStreamFixture(t, "testdata/api/k7/0f3c9d2e-5b5a-11ee-9a3f-2cde48003fdc.json")
+	sheet, ok := stream.Spritesheet()
+	if !ok {
+		t.Fatal("expected a spritesheet")
+	}
+	thumbs := sheet.Thumbnails(stream.Length())
+	if len(thumbs) != 157 {
+		t.Fatalf("got %d thumbnails, expected 157", len(thumbs))
+	}
+	for i, expected := range map[int]Thumbnail{
+		0:   {Image: 0, X: 0, Y: 0, W: 160, H: 90, Start: 0, End: 10 * time.Second},
+		11:  {Image: 0, X: 160, Y: 90, W: 160, H: 90, Start: 110 * time.Second, End: 120 * time.Second},
+		100: {Image: 1, X: 0, Y: 0, W: 160, H: 90, Start: 1000 * time.Second, End: 1010 * time.Second},
+		156: {Image: 1, X: 960, Y: 450, W: 160, H: 90, Start: 1560 * time.Second, End: 1568 * time.Second},
+	} {
+		if thumbs[i] != expected {
+			t.Errorf("thumbnail %d = %+v, expected %+v", i, thumbs[i], expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteThumbnailsVTT(&buf, thumbs[:2], []string{"sprite_0.jpg", "sprite_1.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `WEBVTT
+
+00:00:00.000 --> 00:00:10.000
+sprite_0.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:20.000
+sprite_0.jpg#xywh=160,0,160,90
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+	if err := WriteThumbnailsVTT(&buf, thumbs[100:], []string{"sprite_0.jpg"}); err == nil || !strings.Contains(err.Error(), "image 1") {
+		t.Errorf("expected an error for the missing image name, got %v", err)
+	}
+}
+
+func TestSliceThumbnails(t *testing.T) {
+	// 2x2 grid of 4x3 thumbnails, each filled with its own color
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, colors[y/3*2+x/4])
+		}
+	}
+	sheet := Spritesheet{Width: 4, Height: 3, Images: []string{"sprite_0.jpg"}, Lines: 2, Columns: 2, Interval: 5}
+
+	frames := SliceThumbnails(img, sheet.Thumbnails(15*time.Second))
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, expected 3", len(frames))
+	}
+	for i, frame := range frames {
+		if frame.Bounds().Dx() != 4 || frame.Bounds().Dy() != 3 {
+			t.Errorf("frame %d has the wrong size %v", i, frame.Bounds())
+		}
+		b := frame.Bounds()
+		if got := color.RGBAModel.Convert(frame.At(b.Min.X+1, b.Min.Y+1)); got != colors[i] {
+			t.Errorf("frame %d has the color %v, expected %v", i, got, colors[i])
+		}
+	}
+
+	contact := ContactSheet(frames, 2)
+	if contact.Bounds() != image.Rect(0, 0, 8, 6) {
+		t.Errorf("unexpected contact sheet size %v", contact.Bounds())
+	}
+	if got := color.RGBAModel.Convert(contact.At(1, 4)); got != colors[2] {
+		t.Errorf("got %v, expected %v", got, colors[2])
+	}
+}
@@ -0,0 +1,292 @@
+package ftv
+
+import "time"
+
+// VideoData is the player data embedded in a france.tv episode page. It holds
+// the IDs needed to query the video APIs.
+type VideoData struct {
+	ContentID int       `json:"contentId"`
+	VideoID   string    `json:"videoId"`
+	EndDate   time.Time `json:"endDate"`
+	Tracking  struct {
+		Offre          string `json:"offre"`
+		Support        string `json:"support"`
+		EventType      string `json:"event_type"`
+		Level2         string `json:"level_2"`
+		EventPage      string `json:"event_page"`
+		EventChapitre1 string `json:"event_chapitre1"`
+		EventChapitre2 string `json:"event_chapitre2"`
+	} `json:"tracking"`
+	OriginURL interface{} `json:"originUrl"`
+	// ComingNext []struct {
+	// 	Title    string `json:"title"`
+	// 	PreTitle string `json:"preTitle"`
+	// 	Program  string `json:"program"`
+	// 	Image    string `json:"image"`
+	// } `json:"comingNext"`
+	IsSponsored  bool        `json:"isSponsored"`
+	IsAdVisible  interface{} `json:"isAdVisible"`
+	VideoTitle   string      `json:"videoTitle"`
+	ProgramName  string      `json:"programName"`
+	SeasonNumber int         `json:"seasonNumber"`
+	// Description isn't part of the player data, it's filled from the page
+	// metadata when available.
+	Description string `json:"description,omitempty"`
+}
+
+// StreamDataVideo is the legacy shape of the video section of the player API
+// response.
+type StreamDataVideo struct {
+	Workflow           string        `json:"workflow"`
+	Token              string        `json:"token"`
+	Duration           int           `json:"duration"`
+	Embed              bool          `json:"embed"`
+	Format             string        `json:"format"`
+	OfflineRights      bool          `json:"offline_rights"`
+	IsLive             bool          `json:"is_live"`
+	Drm                interface{}   `json:"drm"`
+	DrmType            interface{}   `json:"drm_type"`
+	LicenseType        interface{}   `json:"license_type"`
+	PlayerVerification bool          `json:"player_verification"`
+	IsDVR              bool          `json:"is_DVR"`
+	Spritesheets       []interface{} `json:"spritesheets"`
+	IsStartoverEnabled bool          `json:"is_startover_enabled"`
+	Previously         struct {
+		Timecode          interface{} `json:"timecode"`
+		Duration          interface{} `json:"duration"`
+		TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
+	} `json:"previously"`
+	ComingNext struct {
+		Timecode interface{} `json:"timecode"`
+		Duration int         `json:"duration"`
+	} `json:"coming_next"`
+	SkipIntro struct {
+		Timecode          interface{} `json:"timecode"`
+		Duration          interface{} `json:"duration"`
+		TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
+	} `json:"skip_intro"`
+	Timeshiftable interface{} `json:"timeshiftable"`
+	URL           string      `json:"url"`
+	DaiType       interface{} `json:"dai_type"`
+	Captions      []Caption   `json:"captions"`
+	Offline       interface{} `json:"offline"`
+}
+
+// Caption is a subtitles file listed by the API next to the stream
+// manifest, most subtitles are only found in the manifests.
+type Caption struct {
+	Format string `json:"format"`
+	Type   string `json:"type"`
+	Lang   string `json:"lang"`
+	URL    string `json:"url"`
+}
+
+// Spritesheet is a set of images holding the preview thumbnails of a video,
+// each image is a grid of Lines x Columns thumbnails taken every Interval
+// seconds.
+type Spritesheet struct {
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Images   []string `json:"images"`
+	Lines    int      `json:"lines"`
+	Columns  int      `json:"columns"`
+	Interval float64  `json:"interval"`
+}
+
+// StreamData is the response of the k7/player webservices API for a given video.
+type StreamData struct {
+	Video struct {
+		Workflow interface{} `json:"workflow"`
+		Token    struct {
+			Akamai string `json:"akamai"`
+		} `json:"token"`
+		Duration           int           `json:"duration"`
+		Embed              bool          `json:"embed"`
+		Format             string        `json:"format"`
+		IsLive             bool          `json:"is_live"`
+		Drm                bool          `json:"drm"`
+		DrmType            interface{}   `json:"drm_type"`
+		LicenseType        interface{}   `json:"license_type"`
+		Spritesheets       []Spritesheet `json:"spritesheets"`
+		Captions           []Caption     `json:"captions"`
+		IsStartoverEnabled bool          `json:"is_startover_enabled"`
+		Previously         struct {
+			Timecode          interface{} `json:"timecode"`
+			Duration          interface{} `json:"duration"`
+			TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
+		} `json:"previously"`
+		ComingNext struct {
+			Timecode          interface{} `json:"timecode"`
+			Duration          interface{} `json:"duration"`
+			TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
+		} `json:"coming_next"`
+		SkipIntro struct {
+			Timecode          interface{} `json:"timecode"`
+			Duration          interface{} `json:"duration"`
+			TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
+		} `json:"skip_intro"`
+		ClosingCredits struct {
+			Timecode interface{} `json:"timecode"`
+		} `json:"closing_credits"`
+		Timeshiftable   interface{} `json:"timeshiftable"`
+		DaiType         interface{} `json:"dai_type"`
+		URL             string      `json:"url"`
+		Offline         interface{} `json:"offline"`
+		IsHighlightable bool        `json:"is_highlightable"`
+		IsEpgable       bool        `json:"is_epgable"`
+		HasHighlights   bool        `json:"has_highlights"`
+	} `json:"video"`
+	Meta struct {
+		ID              string      `json:"id"`
+		Title           string      `json:"title"`
+		AdditionalTitle string      `json:"additional_title"`
+		PreTitle        string      `json:"pre_title"`
+		BroadcastedAt   time.Time   `json:"broadcasted_at"`
+		ImageURL        string      `json:"image_url"`
+		Event           interface{} `json:"event"`
+	} `json:"meta"`
+	Markers struct {
+		Estat struct {
+			CrmID          interface{} `json:"crmID"`
+			Dom            interface{} `json:"dom"`
+			Level1         string      `json:"level1"`
+			Level2         string      `json:"level2"`
+			Level3         string      `json:"level3"`
+			Level4         string      `json:"level4"`
+			Level5         interface{} `json:"level5"`
+			Serial         string      `json:"serial"`
+			StreamGenre    string      `json:"streamGenre"`
+			StreamName     string      `json:"streamName"`
+			StreamDuration int         `json:"streamDuration"`
+			NewLevel1      string      `json:"newLevel1"`
+			NewLevel2      string      `json:"newLevel2"`
+			NewLevel3      string      `json:"newLevel3"`
+			NewLevel4      string      `json:"newLevel4"`
+			NewLevel5      string      `json:"newLevel5"`
+			NewLevel6      string      `json:"newLevel6"`
+			NewLevel7      string      `json:"newLevel7"`
+			NewLevel8      string      `json:"newLevel8"`
+			NewLevel9      interface{} `json:"newLevel9"`
+			NewLevel10     string      `json:"newLevel10"`
+			NewLevel12     interface{} `json:"newLevel12"`
+			NewLevel13     interface{} `json:"newLevel13"`
+			NewLevel14     interface{} `json:"newLevel14"`
+			NewLevel15     interface{} `json:"newLevel15"`
+			MediaContentID string      `json:"mediaContentId"`
+			MediaDiffMode  string      `json:"mediaDiffMode"`
+			MediaChannel   string      `json:"mediaChannel"`
+			NetMeasurement interface{} `json:"netMeasurement"`
+		} `json:"estat"`
+		Npaw struct {
+			Title            string      `json:"title"`
+			TitleEpisode     interface{} `json:"title_episode"`
+			Program          string      `json:"program"`
+			Season           int         `json:"season"`
+			ContentID        string      `json:"content_id"`
+			DrmType          interface{} `json:"drm_type"`
+			Channel          string      `json:"channel"`
+			ContentType      string      `json:"content_type"`
+			ContentGenre     string      `json:"content_genre"`
+			AppVersion       interface{} `json:"app_version"`
+			CustomDimension1 string      `json:"customDimension1"`
+			CustomDimension2 string      `json:"customDimension2"`
+			CustomDimension3 string      `json:"customDimension3"`
+			CustomDimension4 interface{} `json:"customDimension4"`
+			CustomDimension5 interface{} `json:"customDimension5"`
+			CustomDimension6 interface{} `json:"customDimension6"`
+			CustomDimension7 interface{} `json:"customDimension7"`
+			CustomDimension8 string      `json:"customDimension8"`
+			CustomDimension9 interface{} `json:"customDimension9"`
+			ContentSaga      interface{} `json:"content_saga"`
+		} `json:"npaw"`
+		Analytics struct {
+			IsTrackingEnabled bool   `json:"isTrackingEnabled"`
+			IDSite            int    `json:"idSite"`
+			Variant           string `json:"variant"`
+			GeneralPlacement  string `json:"generalPlacement"`
+			DetailedPlacement string `json:"detailedPlacement"`
+			URL               string `json:"url"`
+			Server            string `json:"server"`
+			DiffusionDate     string `json:"diffusionDate"`
+			ProgramName       string `json:"programName"`
+			VideoTitle        string `json:"videoTitle"`
+			VideoProductID    string `json:"videoProductId"`
+			VideoFactoryID    string `json:"videoFactoryId"`
+			Season            int    `json:"season"`
+			VideoType         string `json:"videoType"`
+			VideoCategory     string `json:"videoCategory"`
+			VideoSubCategory  string `json:"videoSubCategory"`
+		} `json:"analytics"`
+		Pub struct {
+			Csid            string        `json:"csid"`
+			Caid            string        `json:"caid"`
+			Afid            string        `json:"afid"`
+			Sfid            string        `json:"sfid"`
+			Capping         interface{}   `json:"capping"`
+			MidrollTimecode []interface{} `json:"midroll_timecode"`
+			IsPreview6H     bool          `json:"isPreview6h"`
+			IsPreview       bool          `json:"isPreview"`
+			MediaTailorURL  interface{}   `json:"mediaTailorUrl"`
+			PollingInterval int           `json:"pollingInterval"`
+			Profile         string        `json:"profile"`
+			Pauseroll       struct {
+				Enabled          bool `json:"enabled"`
+				Delay            int  `json:"delay"`
+				CappingPreroll   int  `json:"cappingPreroll"`
+				CappingPauseroll int  `json:"cappingPauseroll"`
+			} `json:"pauseroll"`
+		} `json:"pub"`
+		Piano struct {
+			IsTrackingEnabled    bool        `json:"isTrackingEnabled"`
+			SiteID               int         `json:"siteId"`
+			ContentStatus        string      `json:"contentStatus"`
+			Channel              string      `json:"channel"`
+			Region               string      `json:"region"`
+			Program              string      `json:"program"`
+			Server               string      `json:"server"`
+			ContentDiffusionDate string      `json:"contentDiffusionDate"`
+			ContentTitle         string      `json:"contentTitle"`
+			ContentType          string      `json:"contentType"`
+			ContentID            string      `json:"contentId"`
+			VideoFactoryID       string      `json:"videoFactoryId"`
+			Season               int         `json:"season"`
+			Category             string      `json:"category"`
+			SubCategory          string      `json:"subCategory"`
+			OriginServer         string      `json:"originServer"`
+			BroadcastingType     string      `json:"broadcastingType"`
+			DaiStatus            bool        `json:"daiStatus"`
+			Highlight            interface{} `json:"highlight"`
+		} `json:"piano"`
+		Maxwell struct {
+			Channel          string      `json:"channel"`
+			Region           string      `json:"region"`
+			ContentStatus    string      `json:"contentStatus"`
+			ContentType      string      `json:"contentType"`
+			Category         string      `json:"category"`
+			ContentID        string      `json:"contentId"`
+			BroadcastingType string      `json:"broadcastingType"`
+			OriginServer     string      `json:"originServer"`
+			DaiStatus        bool        `json:"daiStatus"`
+			Event            interface{} `json:"event"`
+		} `json:"maxwell"`
+	} `json:"markers"`
+	Quanteec struct {
+		Activated         bool        `json:"activated"`
+		VideoID           string      `json:"videoID"`
+		AnalyticsVideoID  string      `json:"analyticsVideoID"`
+		Collection        string      `json:"collection"`
+		P2PConfiguration  string      `json:"p2pConfiguration"`
+		CheckUrls         bool        `json:"checkUrls"`
+		IgnoreTokenInUrls interface{} `json:"ignoreTokenInUrls"`
+		QuanteecKey       string      `json:"quanteecKey"`
+	} `json:"quanteec"`
+}
+
+// Genre returns the genre of the video as reported by the analytics markers,
+// for instance "Documentaire".
+func (s *StreamData) Genre() string {
+	if s.Markers.Npaw.ContentGenre != "" {
+		return s.Markers.Npaw.ContentGenre
+	}
+	return s.Markers.Piano.Category
+}
@@ -7,21 +7,17 @@ go 1.21
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.0
-	github.com/mattetti/m3u8Grabber v0.0.0-20230412212653-5c1dcc39edf4
-	github.com/mattetti/mpdgrabber v0.0.0-20240713052507-0993fdc0d5d5
+	github.com/asticode/go-astisub v0.23.0
+	github.com/mattetti/go-dash v0.0.0-20230103084621-c2498e421aea
 )
 
 require (
-	github.com/abema/go-mp4 v0.9.0 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	github.com/asticode/go-astikit v0.37.0 // indirect
-	github.com/asticode/go-astisub v0.23.0 // indirect
 	github.com/asticode/go-astits v1.11.0 // indirect
-	github.com/barbashov/iso639-3 v0.0.0-20211020172741-1f4ffb2d8d1c // indirect
-	github.com/google/uuid v1.1.2 // indirect
-	github.com/mattetti/go-dash v0.0.0-20230103084621-c2498e421aea // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/zencoder/go-dash/v3 v3.0.3 // indirect
 	golang.org/x/net v0.4.0 // indirect
 	golang.org/x/text v0.5.0 // indirect
-	h12.io/socks v1.0.3 // indirect
+	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/mattetti/francetv/download"
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/manifest"
+)
+
+// job is the download of an episode. Its work directory, inside tmpRoot,
+// holds the segments and the state file so an interrupted download is
+// resumed by the next run. The directory is removed once the video is saved.
+type job struct {
+	dir   string
+	state *download.State
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// openJob returns the job downloading the video to output, resuming the
+// previous one if any.
+func openJob(data ftv.VideoData, output string) (*job, error) {
+	j := &job{dir: filepath.Join(tmpRoot, "job-"+unsafeIDChars.ReplaceAllString(data.VideoID, "_"))}
+	if err := os.MkdirAll(j.dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	state, err := download.LoadState(j.dir)
+	if err != nil {
+		fmt.Printf("Ignoring the state of the previous download in %s - %v\n", j.dir, err)
+	}
+	if state == nil || state.VideoID != data.VideoID {
+		if err := j.reset(); err != nil {
+			return nil, err
+		}
+		state = &download.State{VideoID: data.VideoID}
+	}
+	state.Output = output
+	j.state = state
+	return j, j.save()
+}
+
+// manifest loads the manifest of the stream, from the URL signed for the
+// previous run when its token is still valid.
+func (j *job) manifest(stream *ftv.StreamData) (*manifest.Manifest, error) {
+	if u := j.state.ManifestURL; u != "" {
+		expiry, ok := ftv.TokenExpiry(u)
+		if !ok || time.Until(expiry) > time.Minute {
+			m, err := client.LoadManifest(stream, u)
+			if err == nil {
+				return m, nil
+			}
+			if *debugFlag {
+				fmt.Println("The saved manifest URL failed:", err)
+			}
+		}
+		fmt.Println("The saved manifest URL can't be used anymore, resolving a new one")
+	}
+
+	manifestURL, err := client.ManifestURL(stream)
+	if err != nil {
+		return nil, err
+	}
+	m, err := client.LoadManifest(stream, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	j.state.ManifestURL, j.state.ResolvedAt = manifestURL, time.Now()
+	return m, j.save()
+}
+
+// selection picks the tracks to download. The download of the previous run
+// is resumed if it picked the same tracks, it starts over otherwise, for
+// instance with another -quality or -audio-lang.
+func (j *job) selection(m *manifest.Manifest) (*manifest.Selection, error) {
+	sel, err := m.Select(preferences)
+	if err != nil {
+		return nil, err
+	}
+	if j.state.Selected(sel) {
+		if j.completed() > 0 {
+			fmt.Println("Resuming the download")
+		}
+		return sel, nil
+	}
+	if len(j.state.Tracks) > 0 {
+		// other tracks, the segments can't be reused
+		fmt.Println("The selected tracks changed since the previous run, starting over")
+		if err := j.reset(); err != nil {
+			return nil, err
+		}
+	}
+	j.state.SetSelection(sel)
+	return sel, j.save()
+}
+
+// trackDir returns the directory of the segments of a selected track and
+// records its number of segments. The segments of a previous run are dropped
+// if the track changed.
+func (j *job) trackDir(t *manifest.Track) (string, error) {
+	for i := range j.state.Tracks {
+		ts := &j.state.Tracks[i]
+		if ts.ID != t.ID || ts.Kind != t.Kind.String() {
+			continue
+		}
+		dir := j.segmentsDir(i)
+		if n := download.Count(t); ts.Segments != n {
+			if err := os.RemoveAll(dir); err != nil {
+				return "", err
+			}
+			ts.Segments = n
+		}
+		return dir, j.save()
+	}
+	return "", fmt.Errorf("the %s track %s wasn't selected", t.Kind, t.ID)
+}
+
+// segmentsDir is the directory of the segments of the i-th selected track.
+func (j *job) segmentsDir(i int) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%d-%s", i, j.state.Tracks[i].Kind))
+}
+
+// completed counts the segments already downloaded, a segment file is only
+// written once complete.
+func (j *job) completed() int {
+	n := 0
+	for i := range j.state.Tracks {
+		n += len(download.Completed(j.segmentsDir(i)))
+	}
+	return n
+}
+
+func (j *job) save() error {
+	return j.state.Save(j.dir)
+}
+
+// reset removes the files of a previous download.
+func (j *job) reset() error {
+	if err := os.RemoveAll(j.dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(j.dir, os.ModePerm)
+}
+
+// done removes the work directory once the video is saved.
+func (j *job) done() error {
+	return os.RemoveAll(j.dir)
+}
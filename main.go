@@ -2,63 +2,240 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"strconv"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
-	"github.com/mattetti/m3u8Grabber/m3u8"
-	"github.com/mattetti/mpdgrabber"
+	"github.com/mattetti/francetv/download"
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/manifest"
+	"github.com/mattetti/francetv/mux"
+	"github.com/mattetti/francetv/retry"
+	"github.com/mattetti/francetv/subtitles"
+	"github.com/mattetti/francetv/throttle"
 )
 
 var (
 	debugFlag = flag.Bool("debug", false, "Set debug mode")
 	dlAllFlag = flag.Bool("all", false, "Download all episodes if the page contains multiple videos.")
-	subsOnly  = flag.Bool("subsOnly", false, "Only download the subtitles.")
+	subsOnly  = flag.Bool("subsOnly", false, "Only download the subtitles, see -sub-langs and -sub-format.")
 	URLFlag   = flag.String("url", "", "URL of the page to backup.")
 	hlsFlag   = flag.Bool("m3u8", false, "Should use HLS/m3u8 format to download (instead of dash)")
+	videoFlag = flag.Int("video", -1, "Index of the video to download when the page contains multiple videos.")
+	allVideos = flag.Bool("all-videos", false, "Download all the videos of a page: bonus clips, sign language or audio description versions...")
+	titleFlag = flag.String("video-title", "", "Download the videos of the page whose title contains this text.")
+
+	// collection selection filters
+	includeFlag = flag.String("include", "", "Only download the episodes whose title matches this regular expression.")
+	excludeFlag = flag.String("exclude", "", "Skip the episodes whose title matches this regular expression.")
+	seasonFlag  = flag.String("season", "", "Seasons to download, for instance: 1-3,5")
+	episodeFlag = flag.String("episode", "", "Episodes to download, for instance: 1-10,12-")
+	afterFlag   = flag.String("after", "", "Only download the episodes broadcasted on or after this date (YYYY-MM-DD).")
+	beforeFlag  = flag.String("before", "", "Only download the episodes broadcasted on or before this date (YYYY-MM-DD).")
+	latestFlag  = flag.Int("latest", 0, "Only download the N latest episodes of a collection.")
+
+	outputFlag   = flag.String("output", "default", "Output filename template or preset (default, plex, jellyfin), see the README for the placeholders.")
+	archiveFlag  = flag.String("archive", "", "Path of the download archive file used to skip the videos already downloaded.")
+	sanitizeFlag = flag.String("sanitize", "", "Filename rules: posix, windows or ascii (defaults to the rules of the current platform).")
+	maxNameFlag  = flag.Int("max-filename-length", ftv.DefaultSanitizeMaxLength, "Maximum length, in bytes, of the filenames, 0 to disable.")
+
+	outputDirFlag string
+	byShowFlag    = flag.Bool("by-show", false, "Save the episodes in a directory per show and season, unless the -output template already has directories.")
+	tempDirFlag   = flag.String("temp-dir", "", "Directory used to store the segments while downloading, the interrupted downloads are resumed from there (defaults to the system temp directory).")
+
+	nfoFlag      = flag.Bool("write-nfo", false, "Write a Kodi/Jellyfin .nfo metadata file next to each video.")
+	infoJSONFlag = flag.Bool("write-info-json", false, "Write the raw page and API metadata to a .info.json file next to each video.")
+
+	embedMetadataFlag = flag.Bool("embed-metadata", false, "Write the title, show, season, episode, air date, channel and description in the video file tags.")
+	embedCoverFlag    = flag.Bool("embed-cover", false, "Download the thumbnail and embed it in the video file as cover art.")
+	stripIntroFlag    = flag.Bool("strip-intro", false, "Cut the intro flagged by france.tv out of the video.")
+	stripCreditsFlag  = flag.Bool("strip-credits", false, "Cut the closing credits flagged by france.tv out of the video.")
+	thumbnailsFlag    = flag.Bool("thumbnails", false, "Save the preview thumbnails and a thumbnails.vtt track in a directory next to the video.")
+	contactSheetFlag  = flag.Bool("contact-sheet", false, "With -thumbnails, also save all the thumbnails in a single contact-sheet.png image.")
+	chaptersFlag      = flag.String("chapters", "", "Add the Previously, Intro, Episode and Credits chapters: embed, or write an ffmetadata or ogm sidecar file.")
+
+	listFormatsFlag = flag.Bool("list-formats", false, "List the video, audio and subtitles tracks of the selected episodes without downloading anything.")
+	qualityFlag     = flag.String("quality", "best", "Video quality: best, worst, a maximum height (720) or a maximum bitrate (2500k).")
+	audioLangFlag   = flag.String("audio-lang", "", "Comma separated audio languages, in order of preference, for instance: fra, qad (audio description) or eng (original version).")
+	videoCodecFlag  = flag.String("video-codec", "", "Only download the video in this codec: avc, hevc, av1 or vp9.")
+	formatFlag      = flag.String("format", "", "Container of the videos: mkv, mp4 or ts (defaults to mkv for the DASH streams and mp4 for the HLS ones).")
+	multiAudioFlag  = flag.Bool("multi-audio", false, "Keep an audio track per -audio-lang language, or all the available languages, in the video file.")
+
+	writeSubsFlag   = flag.Bool("write-subs", false, "Save the subtitles next to the video, see -sub-langs and -sub-format.")
+	listSubsFlag    = flag.Bool("list-subs", false, "List the available subtitles with their language and kind (SDH or standard) without downloading anything.")
+	subLangsFlag    = flag.String("sub-langs", "", "Comma separated languages of the subtitles to save, for instance: fra,eng (defaults to all).")
+	subFormatFlag   = flag.String("sub-format", "srt", "Format of the saved subtitles: srt, vtt, ttml or ass.")
+	convertSubsFlag = flag.String("convert-subs", "", "Convert the given subtitles file to -sub-format, next to it, and exit.")
 )
 
-var ErrNoPlayerData = errors.New("no playerData found")
-var ErrMissingPayerJSONData = errors.New("no JSON data found")
-var ErrBadPlayerJSONData = errors.New("Bad JSON data found")
+var (
+	verifyFlag     = flag.Bool("verify", false, "Check the videos recorded in the -archive and exit: the ones failing the verification are quarantined and downloaded again by the next run.")
+	skipVerifyFlag = flag.Bool("skip-verify", false, "Don't verify the duration, tracks and container of the downloaded videos.")
+)
+
+var (
+	retriesFlag       = flag.Int("retries", retry.DefaultPolicy.MaxRetries, "Number of retries of the requests failing with a network error, a 5xx or a 429 status code, 0 to disable.")
+	retryDelayFlag    = flag.Duration("retry-delay", retry.DefaultPolicy.MinDelay, "Delay before the first retry, doubled on each retry.")
+	retryMaxDelayFlag = flag.Duration("retry-max-delay", retry.DefaultPolicy.MaxDelay, "Maximum delay between two retries, longer Retry-After headers aren't waited for.")
+)
+
+var (
+	jobsFlag         = flag.Int("jobs", 1, "Number of episodes resolved and downloaded in parallel.")
+	hostRequestsFlag = flag.Int("host-requests", 8, "Maximum number of simultaneous requests sent to each host, 0 to disable.")
+)
+
+func init() {
+	flag.StringVar(&outputDirFlag, "o", "", "Shorthand for -output-dir.")
+	flag.StringVar(&outputDirFlag, "output-dir", "", "Directory to save the videos to (defaults to the current directory).")
+}
+
+var (
+	filter    *ftv.Filter
+	archive   *ftv.Archive
+	sanitizer = ftv.DefaultSanitizer()
+	// preferences are the tracks to download, set from -quality,
+	// -audio-lang, -multi-audio, -video-codec and -sub-langs
+	preferences manifest.Preferences
+	// container is the -format extension of the videos, picked per stream
+	// when empty.
+	container string
+	// tmpRoot holds the work directories of the downloads, see job, and the
+	// temporary files of the post-processing.
+	tmpRoot string
+	// inProgress are the output paths of the videos being downloaded.
+	inProgress sync.Map
+)
+
+var client = ftv.NewClient()
 
 func main() {
+	os.Exit(run())
+}
+
+// run downloads the videos picked by the flags and returns the exit code.
+func run() int {
 	flag.Parse()
 	if len(os.Args) < 2 {
 		fmt.Println("you need to pass the URL of a FranceTV episode page.")
 		fmt.Println("Take a look at https://www.france.tv/enfants/six-huit-ans/ for ideas")
-		os.Exit(1)
+		return exitFatal
 	}
 	if *debugFlag {
 		fmt.Println("Debug mode enabled")
-		m3u8.Debug = true
-		mpdgrabber.Debug = true
+		download.Debug = true
+		ftv.Debug = true
+		mux.Debug = true
 	}
 
 	if *subsOnly {
 		fmt.Println("Downloading subtitles only")
 	}
 
+	// the page, API, token, manifest and segment requests all share the
+	// retry policy and the per host limit, the requests waiting to be
+	// retried don't hold their host
+	client.HTTPClient = retry.NewClient(throttle.NewClient(nil, *hostRequestsFlag), retry.Policy{
+		MaxRetries: *retriesFlag,
+		MinDelay:   *retryDelayFlag,
+		MaxDelay:   *retryMaxDelayFlag,
+	})
+	if *jobsFlag < 1 {
+		fmt.Println("-jobs needs at least 1 job")
+		return exitFatal
+	}
+	sched = newScheduler(*jobsFlag)
+
+	var err error
+	filter, err = selectionFilter()
+	if err != nil {
+		fmt.Println(err)
+		return exitFatal
+	}
+
+	if err := ftv.ValidateTemplate(*outputFlag); err != nil {
+		fmt.Println("Invalid -output template:", err)
+		return exitFatal
+	}
+
+	if *sanitizeFlag != "" {
+		sanitizer.Profile, err = ftv.ParseSanitizeProfile(*sanitizeFlag)
+		if err != nil {
+			fmt.Println(err)
+			return exitFatal
+		}
+	}
+	sanitizer.MaxLength = *maxNameFlag
+
+	switch *chaptersFlag {
+	case "", "embed", "ffmetadata", "ogm":
+	default:
+		fmt.Printf("Invalid -chapters value %q, expected embed, ffmetadata or ogm\n", *chaptersFlag)
+		return exitFatal
+	}
+
+	subLangs = manifest.ParseLangs(*subLangsFlag)
+	preferences.SubLangs = subLangs
+	preferences.AudioLangs = manifest.ParseLangs(*audioLangFlag)
+	preferences.MultiAudio = *multiAudioFlag
+	if preferences.Quality, err = manifest.ParseQuality(*qualityFlag); err != nil {
+		fmt.Println(err)
+		return exitFatal
+	}
+	if preferences.VideoCodec, err = manifest.ParseVideoCodec(*videoCodecFlag); err != nil {
+		fmt.Println(err)
+		return exitFatal
+	}
+	if *formatFlag != "" {
+		if container, err = mux.ParseContainer(*formatFlag); err != nil {
+			fmt.Println(err)
+			return exitFatal
+		}
+	}
+	if subFormat, err = subtitles.ParseFormat(*subFormatFlag); err != nil {
+		fmt.Println(err)
+		return exitFatal
+	}
+	if *convertSubsFlag != "" {
+		dst := sidecarPath(*convertSubsFlag, "."+subFormat)
+		if err := subtitles.Convert(*convertSubsFlag, dst); err != nil {
+			fmt.Println(err)
+			return exitFatal
+		}
+		fmt.Println("Subtitles saved to", dst)
+		return exitOK
+	}
+
+	if *archiveFlag != "" {
+		archive, err = ftv.OpenArchive(*archiveFlag)
+		if err != nil {
+			fmt.Println("Failed to open the download archive")
+			fmt.Println(err)
+			return exitFatal
+		}
+	}
+
+	if *verifyFlag {
+		return verifyLibrary()
+	}
+
 	givenURL := *URLFlag
 	u, err := url.Parse(givenURL)
 	if err != nil {
 		fmt.Println("Something went wrong when trying to parse", givenURL)
 		fmt.Println(err)
-		os.Exit(1)
+		return exitFatal
 	}
 	if *debugFlag {
 		fmt.Println("Checking", u)
@@ -68,107 +245,116 @@ func main() {
 			*dlAllFlag = true
 		}
 	}
-	w := &sync.WaitGroup{}
-	stopChan := make(chan bool)
-	if *hlsFlag {
-		// start the m3u8 workers
-		m3u8.LaunchWorkers(w, stopChan)
-	} else {
-		mpdgrabber.LaunchWorkers(w, stopChan)
+	tempDir := *tempDirFlag
+	if tempDir == "" {
+		tempDir = os.TempDir()
+	}
+	if tmpRoot, err = segmentsDir(tempDir); err != nil {
+		fmt.Println("Failed to create the temporary directory")
+		fmt.Println(err)
+		return exitFatal
 	}
 
+	downloadVideo := downloadDashVideo
+	if *hlsFlag {
+		downloadVideo = downloadHLSVideo
+	}
 	// let's get all the videos for the replay page
+	var fatal error
 	if strings.Contains(givenURL, "replay-videos") || strings.Contains(givenURL, "toutes-les-videos") {
 		log.Println("Trying to find all videos")
-		urls := collectionURLs(givenURL, nil)
+		urls := collectionURLs(givenURL)
 		log.Printf("%d videos found in %s\n", len(urls), givenURL)
-		for _, pageURL := range urls {
-			if *hlsFlag {
-				downloadHLSVideo(pageURL)
-			} else {
-				downloadDashVideo(pageURL)
-			}
-		}
+		downloadPages(urls, downloadVideo)
 	} else {
-		if *hlsFlag {
-			downloadHLSVideo(givenURL)
-		} else {
-			downloadDashVideo(givenURL)
-		}
+		fatal = downloadVideo(givenURL)
 	}
-
-	if *hlsFlag {
-		close(m3u8.DlChan)
-		w.Wait()
-	} else {
-		mpdgrabber.Close()
-		w.Wait()
+	if err := sched.wait(); fatal == nil {
+		fatal = err
+	}
+	if fatal != nil {
+		fmt.Println(fatal)
 	}
+	if !listingOnly() {
+		summary.print(os.Stdout)
+	}
+	return summary.exitCode(fatal)
 }
 
-func downloadDashVideo(givenURL string) {
+func downloadDashVideo(givenURL string) error {
 
 	// 0. Parse the page to find the product/video IDs
-	data, err := extractVideoDataFromPage(givenURL)
+	videos, err := client.ExtractVideoData(givenURL)
 	if err != nil {
 		// check if we have a collection page instead of a single item page
-		if urls := collectionURLs(givenURL, nil); len(urls) > 0 {
-			for _, pageURL := range urls {
-				downloadDashVideo(pageURL)
-			}
+		if urls := collectionURLs(givenURL); len(urls) > 0 {
+			downloadPages(urls, downloadDashVideo)
+			return nil
 		}
+		return err
+	}
 
-		fmt.Println(err)
-		os.Exit(1)
+	downloadEpisodes(videos, downloadDashEpisode)
+	return nil
+}
+
+// downloadPages queues the pages, the ones that fail are recorded in the run
+// summary.
+func downloadPages(urls []string, downloadVideo func(string) error) {
+	var tasks []task
+	for _, pageURL := range urls {
+		pageURL := pageURL
+		tasks = append(tasks, task{title: pageURL, run: func() error { return downloadVideo(pageURL) }, page: true})
 	}
+	sched.add(tasks...)
+}
 
-	if data == nil {
-		fmt.Println("No video data found in the page and an unexpected lack of error being reported")
-		os.Exit(1)
+// downloadEpisodes queues the videos picked in a page, their outcome is
+// recorded in the run summary.
+func downloadEpisodes(videos []ftv.VideoData, downloadEpisode func(ftv.VideoData) error) {
+	var tasks []task
+	for _, data := range selectVideos(videos) {
+		data := data
+		tasks = append(tasks, task{title: episodeTitle(data), run: func() error { return downloadEpisode(data) }})
+	}
+	sched.add(tasks...)
+}
+
+// episodeTitle names an episode in the messages, before its stream info is
+// known.
+func episodeTitle(data ftv.VideoData) string {
+	if data.ProgramName == "" {
+		return data.VideoTitle
+	}
+	return data.ProgramName + " - " + data.VideoTitle
+}
+
+func downloadDashEpisode(data ftv.VideoData) error {
+	if isArchived(data.VideoID, data.ContentID) {
+		return errSkipped
 	}
-	// fmt.Printf("data: %#v\n", data)
 	productID := data.ContentID
 	videoID := data.VideoID
-	originURL := data.OriginURL.(string)
+	originURL, _ := data.OriginURL.(string)
 
 	// 1. Call the API to get the manifest using the video and product IDs we just recovered
-	stream, err := fetchMPDStreamInfo(videoID, productID, originURL)
+	stream, err := client.MPDStreamInfo(videoID, productID, originURL)
 	if err != nil {
-		fmt.Println("Failed to retrieve the stream info using the FTV API")
-		fmt.Println(err)
-		os.Exit(1)
+		return fmt.Errorf("failed to retrieve the stream info using the FTV API - %w", err)
 	}
-
-	// 2. Using the stream data, prepare the request to get the mpd temp, signed URL
-
-	// fmt.Printf("stream data: %+v\n", stream)
-
-	preTitle := stream.Meta.PreTitle
-	if preTitle == "" {
-		preTitle = data.VideoTitle
+	if !filter.MatchStream(data, stream) {
+		fmt.Printf("Skipping %s - %s, it doesn't match the selection filters\n", stream.Meta.Title, data.VideoTitle)
+		return errSkipped
 	}
-	preTitle = strings.ReplaceAll(preTitle, " ", "")
-	filename := fmt.Sprintf("%s - %s - %s", stream.Meta.Title, preTitle, stream.Meta.AdditionalTitle)
-
-	pathToUse, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
+	if stream.Meta.ID != data.VideoID && isArchived(stream.Meta.ID, 0) {
+		return errSkipped
 	}
 
-	// 3. Download the content
-
-	// TODO: this assumes a mkv output, we might want to pass that as a flag
-	finalFile := filepath.Join(pathToUse, filename+".mkv")
-	if fileAlreadyExists(finalFile) {
-		fmt.Printf("%s already exists\n", finalFile)
-		return
-	}
-	if err := downloadMPDFile(stream, pathToUse, filename); err != nil {
-		fmt.Println("Failed to download the MPD streams file")
-		fmt.Println(err)
-		os.Exit(1)
+	if listingOnly() {
+		return listTracks(data, stream)
 	}
 
+	return downloadStream(data, stream, outputContainer("mkv"))
 }
 
 func strPtr(s *string) string {
@@ -185,623 +371,517 @@ func int64Ptr(d *int64) int {
 	return int(*d)
 }
 
-// extractVideoDataFromPage extracts the video data used to then call the API
-// the data is stored in the HTML page as a JSON object. This function extracts
-// the JSON object and returns a VideoData struct.
-// Note that the script location changes often and the lookup is quite fragile.
-func extractVideoDataFromPage(givenURL string) (*VideoData, error) {
-	res, err := http.Get(givenURL)
+func downloadHLSVideo(givenURL string) error {
+	// 0. Parse the page to find the product/video IDs
+	videos, err := client.ExtractVideoData(givenURL)
 	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		log.Printf("Can't download %s\nStatus code error: %d %s", givenURL, res.StatusCode, res.Status)
-		return nil, fmt.Errorf("Bad Status code fetching %s: %d %s", givenURL, res.StatusCode, res.Status)
+		// check if we have a collection page instead of a single item page
+		if urls := collectionURLs(givenURL); len(urls) > 0 {
+			downloadPages(urls, downloadHLSVideo)
+			return nil
+		}
+		log.Println("Unexpected script content, expected to find FTVPlayerVideos or a video player\nMake sure you picked an episode page.")
+		return err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	downloadEpisodes(videos, downloadHLSEpisode)
+	return nil
+}
+
+func downloadHLSEpisode(data ftv.VideoData) error {
+	if isArchived(data.VideoID, data.ContentID) {
+		return errSkipped
+	}
+	// 1. Fetch the stream info using the FTV API
+	stream, err := client.HLSStreamInfo(data.VideoID)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse the page: %s", err)
+		return fmt.Errorf("something wrong happened when fetching the stream info - %w", err)
 	}
 
-	scriptText := doc.Find("div > div.l-column-left > script").Text()
-	scriptText = strings.TrimSpace(scriptText)
-	if !strings.HasPrefix(scriptText, "window.FTVPlayerVideos") && !strings.HasPrefix(scriptText, "let FTVPlayerVideos") {
-		return nil, ErrNoPlayerData
+	if stream.Video.Format == "dash" {
+		return downloadDashEpisode(data)
 	}
-
-	startIDX := strings.Index(scriptText, "[")
-	endIDX := strings.LastIndex(scriptText, ";")
-	if startIDX < 0 || endIDX <= startIDX {
-		log.Printf("Didn't find the expected json data in %s - %v\n", givenURL, scriptText)
-		if *dlAllFlag {
-			return nil, ErrMissingPayerJSONData
-		}
-		return nil, ErrMissingPayerJSONData
+	if !filter.MatchStream(data, stream) {
+		fmt.Printf("Skipping %s - %s, it doesn't match the selection filters\n", stream.Meta.Title, data.VideoTitle)
+		return errSkipped
 	}
-	log.Printf("Parsing the json data")
-	jsonString := scriptText[startIDX:endIDX]
-	var data []VideoData
-	if err := json.Unmarshal([]byte(jsonString), &data); err != nil {
-		log.Printf("Failed to parse video json data:\n%s\nerr: %v", jsonString, err)
-		return nil, ErrBadPlayerJSONData
+	if stream.Meta.ID != data.VideoID && isArchived(stream.Meta.ID, 0) {
+		return errSkipped
 	}
 
-	return &data[0], nil
-}
-
-func fetchMPDStreamInfo(videoID string, productID int, originURL string) (*StreamData, error) {
-
-	reqURL := fmt.Sprintf("https://k7.ftven.fr/videos/%s?country_code=FR&w=955&h=537&screen_w=1680&screen_h=1050&player_version=5.71.7&domain=www.france.tv&device_type=desktop&browser=chrome&browser_version=108&os=macos&os_version=10_15_7&diffusion_mode=tunnel_first&gmt=0100&video_product_id=%d", videoID, productID)
-
-	req, err := http.NewRequest("GET", reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("could not create request for %s, err: %v", reqURL, err)
-	}
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Language", "fr-FR;q=0.9,fr;q=0.8")
-	req.Header.Set("Dnt", "1")
-	req.Header.Set("Origin", "https://www.france.tv")
-	req.Header.Set("Referer", fmt.Sprintf("https://www.france.tv%s", originURL))
-	req.Header.Set("Sec-Fetch-Dest", "empty")
-	req.Header.Set("Sec-Fetch-Mode", "cors")
-	req.Header.Set("Sec-Fetch-Site", "cross-site")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36")
-	req.Header.Set("Sec-Ch-Ua", "Chromium\";v=\"108\", \"Google Chrome\";v=\"108\"")
-	req.Header.Set("Sec-Ch-Ua-Platform", "\"macOS\"")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request to %s, err: %v", reqURL, err)
-
+	if listingOnly() {
+		return listTracks(data, stream)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to download %s, status code: %d", reqURL, resp.StatusCode)
+	if stream.Video.Format != "hls" {
+		return fmt.Errorf("%s is in an unsupported format: %s", data.VideoTitle, stream.Video.Format)
 	}
+	return downloadStream(data, stream, outputContainer("mp4"))
+}
 
-	var stream StreamData
-	var bodyBuffer bytes.Buffer
-	tee := io.TeeReader(resp.Body, &bodyBuffer)
+// outputContainer returns the -format container, or the default one of the
+// stream format.
+func outputContainer(streamDefault string) string {
+	if container != "" {
+		return container
+	}
+	return streamDefault
+}
 
-	err = json.NewDecoder(tee).Decode(&stream)
+// downloadStream downloads the tracks of the stream picked by the -quality,
+// -audio-lang, -video-codec and -sub-langs flags and muxes them into a file
+// of the given extension.
+func downloadStream(data ftv.VideoData, stream *ftv.StreamData, ext string) error {
+	pathToUse, filename, err := outputPath(data, stream, ext)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse the MPD JSON response data\nerr: %v\nbody: %s", err, bodyBuffer.String())
+		return err
 	}
-	return &stream, nil
-}
 
-// start the download after finding the manifest URL
-func downloadMPDFile(stream *StreamData, outPath, outFilename string) error {
-	var manifestURL string
-	if stream.Video.Token.Akamai == "" {
-		if *debugFlag {
-			fmt.Println("video token not set")
-		}
-		manifestURL = stream.Video.URL
-	} else {
-		tokenURL := fmt.Sprintf("%s&url=%s", stream.Video.Token.Akamai, stream.Video.URL)
-		tokenURL = strings.Replace(tokenURL, "format=json", "format=text", 1)
-		resp, err := http.Get(tokenURL)
-		if err != nil {
-			return fmt.Errorf("failed to fetch the mpd token URL %s - %s", tokenURL, err)
+	finalFile := filepath.Join(pathToUse, filename+"."+ext)
+	// the same video can be listed in several pages
+	if _, loaded := inProgress.LoadOrStore(finalFile, true); loaded {
+		fmt.Printf("%s is already being downloaded\n", finalFile)
+		return errSkipped
+	}
+	defer inProgress.Delete(finalFile)
+	if *subsOnly {
+		if err := writeStreamSubtitles(stream, finalFile); err != nil {
+			return fmt.Errorf("failed to download the subtitles - %w", err)
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("stream for %s not available: %d %s", tokenURL, resp.StatusCode, resp.Status)
+		return nil
+	}
+	if fileAlreadyExists(finalFile) {
+		err := verifyExisting(finalFile, stream)
+		if err == nil {
+			fmt.Printf("%s already exists\n", finalFile)
+			return errSkipped
 		}
-
-		b, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read the mpd token URL %s - %s", tokenURL, err)
+		fmt.Println(err)
+		if err := quarantine(finalFile, finalFile); err != nil {
+			return err
 		}
-		manifestURL = string(b)
 	}
-
-	// we now have the mpd URL
-	if *debugFlag {
-		fmt.Println("MPD manifest URL", manifestURL)
+	if err := ftv.Available(data, stream, time.Now()); err != nil {
+		return err
 	}
 
-	return mpdgrabber.DownloadFromMPDFile(manifestURL, outPath, outFilename)
-}
-
-func getHLSManifestURL(stream *StreamData) (string, error) {
-	var manifestURL string
-	if stream.Video.Token.Akamai == "" {
-		manifestURL = stream.Video.URL
-	} else {
-		tokenURL := strings.Replace(stream.Video.Token.Akamai, "format=json", "format=text", 1)
-		resp, err := http.Get(tokenURL)
+	for attempt := 1; ; attempt++ {
+		j, sel, tracks, err := fetchVideo(data, stream, finalFile)
 		if err != nil {
-			return "", fmt.Errorf("failed to fetch the HLS token URL %s - %s", tokenURL, err)
+			return err
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return "", fmt.Errorf("token API replied with a bad status code: %d %s", resp.StatusCode, resp.Status)
+		err = finishDownload(completedDownload{data: data, stream: stream, selection: sel, file: processingPath(finalFile), expected: expectedTracks(stream, tracks), entry: ftv.ArchiveEntry{
+			VideoID:   data.VideoID,
+			MetaID:    stream.Meta.ID,
+			ContentID: data.ContentID,
+			Title:     filename,
+			Path:      finalFile,
+			Format:    ext,
+		}})
+		if err == nil {
+			// the work directory is kept until the video is in place
+			if err := j.done(); err != nil {
+				fmt.Println("Failed to remove", j.dir)
+			}
+			return nil
 		}
-
-		b, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to read the token URL %s - %s", tokenURL, err)
+		if !errors.Is(err, mux.ErrVerification) {
+			return fmt.Errorf("failed to save %s, run again to resume - %w", finalFile, err)
+		}
+		// the download failed the verification, its tracks are downloaded
+		// again
+		if qErr := quarantine(processingPath(finalFile), finalFile); qErr != nil {
+			return qErr
+		}
+		if err := j.done(); err != nil {
+			fmt.Println("Failed to remove", j.dir)
 		}
-		manifestURL = string(b)
+		if attempt >= verifyAttempts {
+			return err
+		}
+		fmt.Println(err)
+		fmt.Println("Downloading it again")
 	}
-	return manifestURL, nil
 }
 
-func fetchHSLStreamInfo(videoID string) (*StreamData, error) {
-	apiURL := fmt.Sprintf("https://player.webservices.francetelevisions.fr/v1/videos/%s?country_code=FR&w=1024&h=768&version=5.29.4&domain=www.france.tv&device_type=desktop&browser=safari&browser_version=13&os=macos&os_version=10_14_6&diffusion_mode=tunnel_first&gmt=%2B1", videoID)
-
-	req, err := http.NewRequest("GET", apiURL, nil)
+// fetchVideo downloads the tracks of the stream to the processing path of
+// finalFile, resuming the previous download if any, and returns the job,
+// done once the video is saved, and the selected and muxed tracks.
+func fetchVideo(data ftv.VideoData, stream *ftv.StreamData, finalFile string) (*job, *manifest.Selection, []mux.Track, error) {
+	// 2. Resolve the manifest and pick the tracks, or resume the previous
+	// download
+	j, err := openJob(data, finalFile)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request for %s, err: %v", apiURL, err)
+		return nil, nil, nil, err
 	}
-	req.Header.Set("Origin", "https://www.france.tv")
-	// req.Header.Set("user-agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.1 Safari/605.1.15")
-	req.Header.Set("Host", "player.webservices.francetelevisions.fr")
-	req.Header.Set("Referer", "https://www.france.tv/")
-	resp, err := http.DefaultClient.Do(req)
+	m, err := j.manifest(stream)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request to %s, err: %v", apiURL, err)
-
+		return nil, nil, nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to download %s, status code: %d", apiURL, resp.StatusCode)
+	sel, err := j.selection(m)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	var bodyBuffer bytes.Buffer
-	tee := io.TeeReader(resp.Body, &bodyBuffer)
-
-	var stream StreamData
-	err = json.NewDecoder(tee).Decode(&stream)
+	// 3. Download the content
+	fmt.Println("Downloading", finalFile)
+	tracks, err := downloadTracks(j, sel, processingPath(finalFile))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response data\nerr: %v\nbody: %s", err, bodyBuffer.String())
+		return nil, nil, nil, fmt.Errorf("failed to download %s, run again to resume - %w", finalFile, err)
 	}
-	return &stream, nil
+	return j, sel, tracks, nil
 }
 
-func downloadHLSVideo(givenURL string) {
-	// 0. Parse the page to find the product/video IDs
-	data, err := extractVideoDataFromPage(givenURL)
-	if err != nil {
-		// check if we have a collection page instead of a single item page
-		if urls := collectionURLs(givenURL, nil); len(urls) > 0 {
-			for _, pageURL := range urls {
-				downloadHLSVideo(pageURL)
-			}
+// downloadTracks downloads the selected tracks in the work directory of the
+// job and muxes them into dst, the container is picked from the extension.
+// The muxed tracks are returned. The first audio track is played by
+// default, and so are the subtitles when it's in another language.
+func downloadTracks(j *job, sel *manifest.Selection, dst string) ([]mux.Track, error) {
+	work := j.dir
+	var tracks []mux.Track
+	for i, t := range append([]*manifest.Track{sel.Video}, sel.Audio...) {
+		if t == nil {
+			continue
+		}
+		fmt.Println("  ", t)
+		var path string
+		if t.Muxed {
+			// downloaded with the video
+			path = tracks[0].Path
 		} else {
-			log.Println("Unexpected script content, expected to find FTVPlayerVideos or a video player\nMake sure you picked an episode page.")
+			if err := manifest.LoadSegments(client.HTTPClient, t); err != nil {
+				return nil, err
+			}
+			dir, err := j.trackDir(t)
+			if err != nil {
+				return nil, err
+			}
+			path = dir + download.Ext(t)
+			if err := download.Track(client.HTTPClient, t, dir, path); err != nil {
+				return nil, fmt.Errorf("failed to download the %s track - %w", t.Kind, err)
+			}
 		}
-
-		fmt.Println(err)
-		if *dlAllFlag {
-			return
+		if t.Kind == manifest.Video {
+			tracks = append(tracks, mux.Track{Path: path, Kind: mux.Video})
+			continue
+		}
+		tracks = append(tracks, mux.Track{
+			Path:           path,
+			Kind:           mux.Audio,
+			Lang:           manifest.NormalizeLang(t.Lang),
+			Title:          trackTitle(t),
+			Default:        i == 1,
+			VisualImpaired: t.AudioDescription,
+		})
+	}
+
+	var audioLang string
+	if len(sel.Audio) > 0 {
+		audioLang = manifest.NormalizeLang(sel.Audio[0].Lang)
+	}
+	defaultSubs := false
+	for i, t := range sel.Text {
+		subs, err := subtitles.Fetch(client.HTTPClient, t)
+		if err == nil {
+			path := filepath.Join(work, fmt.Sprintf("text-%d.srt", i))
+			if err = subs.Write(path); err == nil {
+				lang := manifest.NormalizeLang(t.Lang)
+				// original version with subtitles
+				isDefault := !defaultSubs && !t.SDH && audioLang != "" && audioLang != "qad" && lang != audioLang
+				defaultSubs = defaultSubs || isDefault
+				tracks = append(tracks, mux.Track{
+					Path:            path,
+					Kind:            mux.Subtitles,
+					Lang:            lang,
+					Title:           trackTitle(t),
+					Default:         isDefault,
+					HearingImpaired: t.SDH,
+				})
+			}
+		}
+		if err != nil {
+			fmt.Printf("Skipping the %s subtitles - %v\n", t.Lang, err)
 		}
-
-		os.Exit(1)
 	}
 
-	if data == nil {
-		fmt.Println("No video data found in the page and an unexpected lack of error being reported")
-		os.Exit(1)
+	ext := filepath.Ext(dst)
+	if len(sel.Text) > 0 && !mux.SupportsSubtitles(ext) {
+		fmt.Printf("The %s files can't hold subtitles, use -write-subs to save them next to the video\n", strings.TrimPrefix(ext, "."))
+		tracks = slices.DeleteFunc(tracks, func(t mux.Track) bool { return t.Kind == mux.Subtitles })
 	}
+	return tracks, mux.MergeEdit(ext, tracks).ApplyTo(tracks[0].Path, dst)
+}
 
-	// 1. Fetch the stream info using the FTV API
-	stream, err := fetchHSLStreamInfo(data.VideoID)
+// trackTitle names the audio and subtitles tracks shown by the players next
+// to their language.
+func trackTitle(t *manifest.Track) string {
+	switch {
+	case t.Label != "":
+		return t.Label
+	case t.AudioDescription:
+		return "Audio description"
+	case t.SDH:
+		return "SDH"
+	}
+	return ""
+}
+
+// listTracks prints the tracks of the stream, only the subtitles with
+// -list-subs.
+func listTracks(data ftv.VideoData, stream *ftv.StreamData) error {
+	m, err := client.Manifest(stream)
 	if err != nil {
-		log.Println("something wrong happened when fetching the stream info", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load the manifest of %s - %w", data.VideoTitle, err)
 	}
-
-	if stream.Video.Format == "dash" {
-		downloadDashVideo(givenURL)
-		return
+	tracks := m.Tracks
+	if !*listFormatsFlag {
+		tracks = m.TracksOf(manifest.Text)
 	}
-
-	preTitle := stream.Meta.PreTitle
-	if preTitle == "" {
-		preTitle = data.VideoTitle
+	length := m.Duration
+	if length == 0 {
+		length = stream.Length()
 	}
-	preTitle = strings.ReplaceAll(preTitle, " ", "")
-	filename := fmt.Sprintf("%s - %s - %s", stream.Meta.Title, preTitle, stream.Meta.AdditionalTitle)
+	fmt.Printf("\n%s - %s (%s, %s, %d tracks)\n", stream.Meta.Title, data.VideoTitle, m.Format, length.Round(time.Second), len(tracks))
+	return manifest.WriteTable(os.Stdout, tracks)
+}
 
-	pathToUse, err := os.Getwd()
+// outputPath renders the -output template inside the -output-dir directory
+// and returns the directory and the filename, without extension, to save the
+// video to. The directories are created as needed.
+func outputPath(data ftv.VideoData, stream *ftv.StreamData, ext string) (dir, filename string, err error) {
+	name, err := ftv.RenderName(*outputFlag, ftv.NewNameFields(data, stream, ext), sanitizer)
 	if err != nil {
-		log.Fatal(err)
+		return "", "", fmt.Errorf("failed to render the output filename - %w", err)
 	}
-
-	destPath := filepath.Join(pathToUse, filename+".mp4")
-	if fileAlreadyExists(destPath) {
-		fmt.Printf("%s already exists\n", destPath)
-		return
+	if *byShowFlag && !strings.Contains(name, "/") {
+		name = path.Join(ftv.ShowDir(ftv.NewNameFields(data, stream, ext), sanitizer), name)
 	}
-
-	// 2. Fetch the actual manifest URL (m3u8)
-	manifestURL, err := getHLSManifestURL(stream)
+	pathToUse := outputDirFlag
+	if pathToUse == "" {
+		if pathToUse, err = os.Getwd(); err != nil {
+			return "", "", err
+		}
+	}
+	fullPath, err := ftv.JoinOutput(pathToUse, name)
 	if err != nil {
-		log.Println("something wrong happened when fetching the manifest URL", err)
-		os.Exit(1)
+		return "", "", err
+	}
+	dir = filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", "", fmt.Errorf("failed to create the output directory %s - %w", dir, err)
 	}
+	return dir, strings.TrimSuffix(filepath.Base(fullPath), "."+ext), nil
+}
 
-	if *debugFlag {
-		log.Printf("Manifest file: %s\n", manifestURL)
+// segmentsDir creates the directory inside dir holding the work directories
+// of the downloads. It's kept between the runs so the interrupted downloads
+// can be resumed.
+func segmentsDir(dir string) (string, error) {
+	tmpDir := filepath.Join(dir, "francetv")
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return "", err
 	}
+	return tmpDir, nil
+}
 
-	fmt.Printf("Queing up %s\n", destPath)
+// listingOnly reports whether the tracks are listed instead of downloaded.
+func listingOnly() bool {
+	return *listFormatsFlag || *listSubsFlag
+}
 
-	// 3. Queue the video to download
-	if stream.Video.Format == "hls" {
-		job := &m3u8.WJob{
-			Type:     m3u8.ListDL,
-			URL:      manifestURL,
-			SubsOnly: *subsOnly,
-			// SkipConverter: true,
-			DestPath: pathToUse,
-			Filename: filename}
-		m3u8.DlChan <- job
-		return
+// isArchived checks the download archive, if any, and reports the videos
+// already downloaded. The archive is ignored when only listing the tracks.
+func isArchived(videoID string, contentID int) bool {
+	if listingOnly() {
+		return false
+	}
+	entry, ok := archive.Has(videoID, contentID)
+	if ok {
+		fmt.Printf("%s was already downloaded on %s to %s\n", entry.Title, entry.DownloadedAt.Format("2006-01-02"), entry.Path)
 	}
+	return ok
+}
 
-	fmt.Printf("%s is in an unsupported format: %s\n", filename, stream.Video.Format)
+func addToArchive(entry ftv.ArchiveEntry) {
+	if err := archive.Add(entry); err != nil {
+		fmt.Println("Failed to update the download archive")
+		fmt.Println(err)
+	}
 }
 
-func collectionURLs(givenURL string, episodeURLs []string) []string {
-	res, err := http.Get(givenURL)
-	if err != nil {
-		log.Fatal(err)
+// selectVideos lists the videos found in a page and returns the ones picked
+// by the -video, -video-title or -all-videos flags. The first video, which is
+// the main one, is returned by default.
+func selectVideos(videos []ftv.VideoData) []ftv.VideoData {
+	if len(videos) > 1 {
+		fmt.Printf("%d videos found in the page:\n", len(videos))
+		for i, v := range videos {
+			fmt.Printf("  [%d] %s - %s (%s)\n", i, v.ProgramName, v.VideoTitle, v.VideoID)
+		}
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+
+	switch {
+	case *videoFlag >= 0:
+		if *videoFlag >= len(videos) {
+			fmt.Printf("No video at index %d, only %d videos were found\n", *videoFlag, len(videos))
+			return nil
+		}
+		return videos[*videoFlag : *videoFlag+1]
+	case *titleFlag != "":
+		var selected []ftv.VideoData
+		for _, v := range videos {
+			if strings.Contains(strings.ToLower(v.VideoTitle), strings.ToLower(*titleFlag)) {
+				selected = append(selected, v)
+			}
+		}
+		if len(selected) == 0 {
+			fmt.Printf("No video title matching %q\n", *titleFlag)
+		}
+		return selected
+	case *allVideos:
+		return videos
 	}
 
-	// Load the HTML document
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if len(videos) > 1 {
+		fmt.Println("Downloading the first one, use -video, -video-title or -all-videos to pick others")
+	}
+	return videos[:1]
+}
+
+var (
+	// stdin reads the answers to the prompts, guarded by promptMu.
+	stdin    = bufio.NewReader(os.Stdin)
+	promptMu sync.Mutex
+)
+
+// collectionURLs lists the episodes of a collection page and returns the ones
+// picked by the selection filters. Without filters, the user is asked which
+// ones to download unless -all was passed or stdin isn't a terminal.
+func collectionURLs(givenURL string) []string {
+	cards, err := client.Collection(givenURL)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 	}
-	if episodeURLs == nil {
-		episodeURLs = []string{}
+	if len(cards) == 0 {
+		log.Println("No videos found on this page")
+		return nil
 	}
-	count := 0
 
-	doc.Find("a.c-card-16x9").Each(func(i int, s *goquery.Selection) {
-		count++
-		href, _ := s.Attr("href")
-		videoPageURL := fmt.Sprintf("https://france.tv%s", href)
-		title := s.Find(".c-card-16x9__subtitle").First().Text()
-		fmt.Println("Do you want to download", title, "? (Type y for Yes)")
-		if *dlAllFlag {
-			episodeURLs = append(episodeURLs, videoPageURL)
-		} else {
-			reader := bufio.NewReader(os.Stdin)
-			inputText, _ := reader.ReadString('\n')
-			inputText = strings.TrimSpace(inputText)
-			if inputText == "y" || inputText == "Y" {
-				episodeURLs = append(episodeURLs, videoPageURL)
-			}
+	episodeURLs := []string{}
+	if !filter.IsZero() {
+		for _, card := range filter.Cards(cards) {
+			fmt.Println("Selected", card.Title)
+			episodeURLs = append(episodeURLs, card.URL)
 		}
-	})
+		return episodeURLs
+	}
 
-	if count == 0 && len(episodeURLs) == 0 {
-		log.Println("No videos found on this page")
+	// listing the tracks is harmless, list all the episodes
+	listing := listingOnly()
+	if !*dlAllFlag && !listing && !stdinIsTerminal() {
+		log.Println("Not prompting for the episodes to download since stdin isn't a terminal, use -all or the selection filters")
+		return nil
 	}
 
-	if count > 0 {
-		if !strings.Contains(givenURL, "?page") {
-			fmt.Println("Checking pagination")
-			return collectionURLs(givenURL+"/?page=1", episodeURLs)
-		} else {
-			idx := strings.LastIndex(givenURL, "page=")
-			if idx > 0 {
-				currentPage, err := strconv.Atoi(givenURL[idx+5:])
-				if err != nil {
-					fmt.Printf("Couldn't get the next page - %v", err)
-					return episodeURLs
-				}
-				nextURL := givenURL[:idx+5] + strconv.Itoa(currentPage+1)
-				return collectionURLs(nextURL, episodeURLs)
-			}
+	// the collections found by parallel jobs are asked about one at a time
+	promptMu.Lock()
+	defer promptMu.Unlock()
+	for _, card := range cards {
+		if listing {
+			episodeURLs = append(episodeURLs, card.URL)
+			continue
+		}
+		fmt.Println("Do you want to download", card.Title, "? (Type y for Yes)")
+		if *dlAllFlag {
+			episodeURLs = append(episodeURLs, card.URL)
+			continue
+		}
+		inputText, _ := stdin.ReadString('\n')
+		inputText = strings.TrimSpace(inputText)
+		if inputText == "y" || inputText == "Y" {
+			episodeURLs = append(episodeURLs, card.URL)
 		}
 	}
 
 	return episodeURLs
 }
 
-func downloadFile(url string, path string) (*os.File, error) {
-	// Create the file
-	out, err := os.Create(path)
-	if err != nil {
-		return nil, err
+// selectionFilter builds the episode filter from the command line flags.
+func selectionFilter() (*ftv.Filter, error) {
+	f := &ftv.Filter{Latest: *latestFlag}
+	var err error
+	if *includeFlag != "" {
+		if f.Include, err = regexp.Compile(*includeFlag); err != nil {
+			return nil, fmt.Errorf("invalid -include expression - %w", err)
+		}
+	}
+	if *excludeFlag != "" {
+		if f.Exclude, err = regexp.Compile(*excludeFlag); err != nil {
+			return nil, fmt.Errorf("invalid -exclude expression - %w", err)
+		}
+	}
+	if f.Seasons, err = ftv.ParseRanges(*seasonFlag); err != nil {
+		return nil, fmt.Errorf("invalid -season value - %w", err)
 	}
+	if f.Episodes, err = ftv.ParseRanges(*episodeFlag); err != nil {
+		return nil, fmt.Errorf("invalid -episode value - %w", err)
+	}
+	if *afterFlag != "" {
+		if f.After, err = time.ParseInLocation("2006-01-02", *afterFlag, time.Local); err != nil {
+			return nil, fmt.Errorf("invalid -after date - %w", err)
+		}
+	}
+	if *beforeFlag != "" {
+		if f.Before, err = time.ParseInLocation("2006-01-02", *beforeFlag, time.Local); err != nil {
+			return nil, fmt.Errorf("invalid -before date - %w", err)
+		}
+		// the whole day is included
+		f.Before = f.Before.AddDate(0, 0, 1)
+	}
+	return f, nil
+}
 
-	// for mpd
-	// "Accept", "application/dash+xml,video/vnd.mpeg.dash.mpd"
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
 
-	// Get the data
-	resp, err := http.Get(url)
+// downloadFile saves the body of url to path and returns it opened for
+// reading. The body is written to a temporary file renamed once complete, so
+// a failed download doesn't leave a partial or empty file behind.
+func downloadFile(url string, path string) (*os.File, error) {
+	resp, err := client.HTTPClient.Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-
-	// Check server response
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
+		return nil, fmt.Errorf("bad status fetching %s: %s", url, resp.Status)
 	}
 
-	// Write the body to file
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	if err != nil {
+		return nil, err
+	}
 	_, err = io.Copy(out, resp.Body)
+	// Windows can't rename an open file
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), path)
+	}
 	if err != nil {
+		os.Remove(out.Name())
 		return nil, err
 	}
-
-	return out, nil
+	return os.Open(path)
 }
 
 func fileAlreadyExists(path string) bool {
 	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
 }
-
-type VideoData struct {
-	ContentID int       `json:"contentId"`
-	VideoID   string    `json:"videoId"`
-	EndDate   time.Time `json:"endDate"`
-	Tracking  struct {
-		Offre          string `json:"offre"`
-		Support        string `json:"support"`
-		EventType      string `json:"event_type"`
-		Level2         string `json:"level_2"`
-		EventPage      string `json:"event_page"`
-		EventChapitre1 string `json:"event_chapitre1"`
-		EventChapitre2 string `json:"event_chapitre2"`
-	} `json:"tracking"`
-	OriginURL interface{} `json:"originUrl"`
-	// ComingNext []struct {
-	// 	Title    string `json:"title"`
-	// 	PreTitle string `json:"preTitle"`
-	// 	Program  string `json:"program"`
-	// 	Image    string `json:"image"`
-	// } `json:"comingNext"`
-	IsSponsored  bool        `json:"isSponsored"`
-	IsAdVisible  interface{} `json:"isAdVisible"`
-	VideoTitle   string      `json:"videoTitle"`
-	ProgramName  string      `json:"programName"`
-	SeasonNumber int         `json:"seasonNumber"`
-}
-
-type StreamDataVideo struct {
-	Workflow           string        `json:"workflow"`
-	Token              string        `json:"token"`
-	Duration           int           `json:"duration"`
-	Embed              bool          `json:"embed"`
-	Format             string        `json:"format"`
-	OfflineRights      bool          `json:"offline_rights"`
-	IsLive             bool          `json:"is_live"`
-	Drm                interface{}   `json:"drm"`
-	DrmType            interface{}   `json:"drm_type"`
-	LicenseType        interface{}   `json:"license_type"`
-	PlayerVerification bool          `json:"player_verification"`
-	IsDVR              bool          `json:"is_DVR"`
-	Spritesheets       []interface{} `json:"spritesheets"`
-	IsStartoverEnabled bool          `json:"is_startover_enabled"`
-	Previously         struct {
-		Timecode          interface{} `json:"timecode"`
-		Duration          interface{} `json:"duration"`
-		TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
-	} `json:"previously"`
-	ComingNext struct {
-		Timecode interface{} `json:"timecode"`
-		Duration int         `json:"duration"`
-	} `json:"coming_next"`
-	SkipIntro struct {
-		Timecode          interface{} `json:"timecode"`
-		Duration          interface{} `json:"duration"`
-		TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
-	} `json:"skip_intro"`
-	Timeshiftable interface{}   `json:"timeshiftable"`
-	URL           string        `json:"url"`
-	DaiType       interface{}   `json:"dai_type"`
-	Captions      []interface{} `json:"captions"`
-	Offline       interface{}   `json:"offline"`
-}
-
-type StreamData struct {
-	Video struct {
-		Workflow interface{} `json:"workflow"`
-		Token    struct {
-			Akamai string `json:"akamai"`
-		} `json:"token"`
-		Duration     int         `json:"duration"`
-		Embed        bool        `json:"embed"`
-		Format       string      `json:"format"`
-		IsLive       bool        `json:"is_live"`
-		Drm          bool        `json:"drm"`
-		DrmType      interface{} `json:"drm_type"`
-		LicenseType  interface{} `json:"license_type"`
-		Spritesheets []struct {
-			Width    int      `json:"width"`
-			Height   int      `json:"height"`
-			Images   []string `json:"images"`
-			Lines    int      `json:"lines"`
-			Columns  int      `json:"columns"`
-			Interval float64  `json:"interval"`
-		} `json:"spritesheets"`
-		IsStartoverEnabled bool `json:"is_startover_enabled"`
-		Previously         struct {
-			Timecode          interface{} `json:"timecode"`
-			Duration          interface{} `json:"duration"`
-			TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
-		} `json:"previously"`
-		ComingNext struct {
-			Timecode          interface{} `json:"timecode"`
-			Duration          interface{} `json:"duration"`
-			TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
-		} `json:"coming_next"`
-		SkipIntro struct {
-			Timecode          interface{} `json:"timecode"`
-			Duration          interface{} `json:"duration"`
-			TimeBeforeDismiss interface{} `json:"time_before_dismiss"`
-		} `json:"skip_intro"`
-		ClosingCredits struct {
-			Timecode interface{} `json:"timecode"`
-		} `json:"closing_credits"`
-		Timeshiftable   interface{} `json:"timeshiftable"`
-		DaiType         interface{} `json:"dai_type"`
-		URL             string      `json:"url"`
-		Offline         interface{} `json:"offline"`
-		IsHighlightable bool        `json:"is_highlightable"`
-		IsEpgable       bool        `json:"is_epgable"`
-		HasHighlights   bool        `json:"has_highlights"`
-	} `json:"video"`
-	Meta struct {
-		ID              string      `json:"id"`
-		Title           string      `json:"title"`
-		AdditionalTitle string      `json:"additional_title"`
-		PreTitle        string      `json:"pre_title"`
-		BroadcastedAt   time.Time   `json:"broadcasted_at"`
-		ImageURL        string      `json:"image_url"`
-		Event           interface{} `json:"event"`
-	} `json:"meta"`
-	Markers struct {
-		Estat struct {
-			CrmID          interface{} `json:"crmID"`
-			Dom            interface{} `json:"dom"`
-			Level1         string      `json:"level1"`
-			Level2         string      `json:"level2"`
-			Level3         string      `json:"level3"`
-			Level4         string      `json:"level4"`
-			Level5         interface{} `json:"level5"`
-			Serial         string      `json:"serial"`
-			StreamGenre    string      `json:"streamGenre"`
-			StreamName     string      `json:"streamName"`
-			StreamDuration int         `json:"streamDuration"`
-			NewLevel1      string      `json:"newLevel1"`
-			NewLevel2      string      `json:"newLevel2"`
-			NewLevel3      string      `json:"newLevel3"`
-			NewLevel4      string      `json:"newLevel4"`
-			NewLevel5      string      `json:"newLevel5"`
-			NewLevel6      string      `json:"newLevel6"`
-			NewLevel7      string      `json:"newLevel7"`
-			NewLevel8      string      `json:"newLevel8"`
-			NewLevel9      interface{} `json:"newLevel9"`
-			NewLevel10     string      `json:"newLevel10"`
-			NewLevel12     interface{} `json:"newLevel12"`
-			NewLevel13     interface{} `json:"newLevel13"`
-			NewLevel14     interface{} `json:"newLevel14"`
-			NewLevel15     interface{} `json:"newLevel15"`
-			MediaContentID string      `json:"mediaContentId"`
-			MediaDiffMode  string      `json:"mediaDiffMode"`
-			MediaChannel   string      `json:"mediaChannel"`
-			NetMeasurement interface{} `json:"netMeasurement"`
-		} `json:"estat"`
-		Npaw struct {
-			Title            string      `json:"title"`
-			TitleEpisode     interface{} `json:"title_episode"`
-			Program          string      `json:"program"`
-			Season           int         `json:"season"`
-			ContentID        string      `json:"content_id"`
-			DrmType          interface{} `json:"drm_type"`
-			Channel          string      `json:"channel"`
-			ContentType      string      `json:"content_type"`
-			ContentGenre     string      `json:"content_genre"`
-			AppVersion       interface{} `json:"app_version"`
-			CustomDimension1 string      `json:"customDimension1"`
-			CustomDimension2 string      `json:"customDimension2"`
-			CustomDimension3 string      `json:"customDimension3"`
-			CustomDimension4 interface{} `json:"customDimension4"`
-			CustomDimension5 interface{} `json:"customDimension5"`
-			CustomDimension6 interface{} `json:"customDimension6"`
-			CustomDimension7 interface{} `json:"customDimension7"`
-			CustomDimension8 string      `json:"customDimension8"`
-			CustomDimension9 interface{} `json:"customDimension9"`
-			ContentSaga      interface{} `json:"content_saga"`
-		} `json:"npaw"`
-		Analytics struct {
-			IsTrackingEnabled bool   `json:"isTrackingEnabled"`
-			IDSite            int    `json:"idSite"`
-			Variant           string `json:"variant"`
-			GeneralPlacement  string `json:"generalPlacement"`
-			DetailedPlacement string `json:"detailedPlacement"`
-			URL               string `json:"url"`
-			Server            string `json:"server"`
-			DiffusionDate     string `json:"diffusionDate"`
-			ProgramName       string `json:"programName"`
-			VideoTitle        string `json:"videoTitle"`
-			VideoProductID    string `json:"videoProductId"`
-			VideoFactoryID    string `json:"videoFactoryId"`
-			Season            int    `json:"season"`
-			VideoType         string `json:"videoType"`
-			VideoCategory     string `json:"videoCategory"`
-			VideoSubCategory  string `json:"videoSubCategory"`
-		} `json:"analytics"`
-		Pub struct {
-			Csid            string        `json:"csid"`
-			Caid            string        `json:"caid"`
-			Afid            string        `json:"afid"`
-			Sfid            string        `json:"sfid"`
-			Capping         interface{}   `json:"capping"`
-			MidrollTimecode []interface{} `json:"midroll_timecode"`
-			IsPreview6H     bool          `json:"isPreview6h"`
-			IsPreview       bool          `json:"isPreview"`
-			MediaTailorURL  interface{}   `json:"mediaTailorUrl"`
-			PollingInterval int           `json:"pollingInterval"`
-			Profile         string        `json:"profile"`
-			Pauseroll       struct {
-				Enabled          bool `json:"enabled"`
-				Delay            int  `json:"delay"`
-				CappingPreroll   int  `json:"cappingPreroll"`
-				CappingPauseroll int  `json:"cappingPauseroll"`
-			} `json:"pauseroll"`
-		} `json:"pub"`
-		Piano struct {
-			IsTrackingEnabled    bool        `json:"isTrackingEnabled"`
-			SiteID               int         `json:"siteId"`
-			ContentStatus        string      `json:"contentStatus"`
-			Channel              string      `json:"channel"`
-			Region               string      `json:"region"`
-			Program              string      `json:"program"`
-			Server               string      `json:"server"`
-			ContentDiffusionDate string      `json:"contentDiffusionDate"`
-			ContentTitle         string      `json:"contentTitle"`
-			ContentType          string      `json:"contentType"`
-			ContentID            string      `json:"contentId"`
-			VideoFactoryID       string      `json:"videoFactoryId"`
-			Season               int         `json:"season"`
-			Category             string      `json:"category"`
-			SubCategory          string      `json:"subCategory"`
-			OriginServer         string      `json:"originServer"`
-			BroadcastingType     string      `json:"broadcastingType"`
-			DaiStatus            bool        `json:"daiStatus"`
-			Highlight            interface{} `json:"highlight"`
-		} `json:"piano"`
-		Maxwell struct {
-			Channel          string      `json:"channel"`
-			Region           string      `json:"region"`
-			ContentStatus    string      `json:"contentStatus"`
-			ContentType      string      `json:"contentType"`
-			Category         string      `json:"category"`
-			ContentID        string      `json:"contentId"`
-			BroadcastingType string      `json:"broadcastingType"`
-			OriginServer     string      `json:"originServer"`
-			DaiStatus        bool        `json:"daiStatus"`
-			Event            interface{} `json:"event"`
-		} `json:"maxwell"`
-	} `json:"markers"`
-	Quanteec struct {
-		Activated         bool        `json:"activated"`
-		VideoID           string      `json:"videoID"`
-		AnalyticsVideoID  string      `json:"analyticsVideoID"`
-		Collection        string      `json:"collection"`
-		P2PConfiguration  string      `json:"p2pConfiguration"`
-		CheckUrls         bool        `json:"checkUrls"`
-		IgnoreTokenInUrls interface{} `json:"ignoreTokenInUrls"`
-		QuanteecKey       string      `json:"quanteecKey"`
-	} `json:"quanteec"`
-}
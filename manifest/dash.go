@@ -0,0 +1,369 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattetti/go-dash/mpd"
+)
+
+// accessibility scheme used by france.tv to flag the audio description and
+// the subtitles for the hard of hearing.
+const audioPurposeScheme = "urn:tva:metadata:cs:AudioPurposeCS:2007"
+
+// ParseDASH parses an MPD manifest. The segments of every representation are
+// listed, baseURL is the URL of the manifest.
+func ParseDASH(r io.Reader, baseURL string) (*Manifest, error) {
+	doc, err := mpd.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the MPD manifest - %w", err)
+	}
+	if doc.Type != nil && *doc.Type == "dynamic" {
+		return nil, fmt.Errorf("live MPD manifests aren't supported")
+	}
+
+	m := &Manifest{Format: DASH, URL: baseURL}
+	if doc.MediaPresentationDuration != nil {
+		m.Duration, _ = mpd.ParseDuration(*doc.MediaPresentationDuration)
+	}
+
+	base := firstBaseURL(baseURL, doc.BaseURL)
+	byID := map[string]*Track{}
+	for _, period := range doc.Periods {
+		periodBase := firstBaseURL(base, period.BaseURL)
+		periodDuration := time.Duration(period.Duration)
+		if periodDuration == 0 && len(doc.Periods) == 1 {
+			periodDuration = m.Duration
+		}
+
+		for _, as := range period.AdaptationSets {
+			setBase := firstBaseURL(periodBase, as.BaseURL)
+			for _, rep := range as.Representations {
+				t := dashTrack(as, rep)
+				if t == nil {
+					continue
+				}
+				repBase := firstBaseURL(setBase, rep.BaseURL)
+				t.Init, t.Segments, err = dashSegments(repBase, period, as, rep, periodDuration)
+				if err != nil {
+					return nil, fmt.Errorf("representation %s - %w", t.ID, err)
+				}
+
+				// multi period manifests: continue the tracks of the
+				// previous periods
+				key := t.Kind.String() + "/" + t.ID
+				if prev, ok := byID[key]; ok && t.ID != "" {
+					prev.Segments = append(prev.Segments, t.Segments...)
+					continue
+				}
+				byID[key] = t
+				m.Tracks = append(m.Tracks, t)
+			}
+		}
+	}
+	return m, nil
+}
+
+// firstBaseURL resolves the first of the BaseURL elements, if any.
+func firstBaseURL(base string, elements []string) string {
+	if len(elements) == 0 {
+		return base
+	}
+	return resolve(base, strings.TrimSpace(elements[0]))
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// dashKind guesses the kind of content of a representation, false is
+// returned for the content we don't download, such as the thumbnails.
+func dashKind(contentType, mimeType, codecs string) (Kind, bool) {
+	switch strings.ToLower(contentType) {
+	case "video":
+		return Video, true
+	case "audio":
+		return Audio, true
+	case "text":
+		return Text, true
+	case "image":
+		return 0, false
+	}
+	mimeType = strings.ToLower(mimeType)
+	switch {
+	case strings.HasPrefix(mimeType, "video/"):
+		return Video, true
+	case strings.HasPrefix(mimeType, "audio/"):
+		return Audio, true
+	case strings.HasPrefix(mimeType, "text/"), strings.Contains(mimeType, "ttml"):
+		return Text, true
+	case strings.HasPrefix(mimeType, "image/"):
+		return 0, false
+	}
+	codecs = strings.ToLower(codecs)
+	for _, prefix := range []string{"stpp", "wvtt"} {
+		if strings.HasPrefix(codecs, prefix) {
+			return Text, true
+		}
+	}
+	for _, prefix := range []string{"mp4a", "ac-3", "ec-3", "opus"} {
+		if strings.HasPrefix(codecs, prefix) {
+			return Audio, true
+		}
+	}
+	for _, prefix := range []string{"avc", "hvc", "hev", "vp09", "av01"} {
+		if strings.HasPrefix(codecs, prefix) {
+			return Video, true
+		}
+	}
+	return 0, false
+}
+
+func dashTrack(as *mpd.AdaptationSet, rep *mpd.Representation) *Track {
+	t := &Track{
+		ID:        str(rep.ID),
+		Lang:      str(as.Lang),
+		Label:     str(as.Label),
+		MimeType:  str(rep.MimeType),
+		Codecs:    str(rep.Codecs),
+		FrameRate: str(rep.FrameRate),
+		Protected: len(as.ContentProtection) > 0 || len(rep.ContentProtection) > 0,
+	}
+	if t.MimeType == "" {
+		t.MimeType = str(as.MimeType)
+	}
+	if t.Codecs == "" {
+		t.Codecs = str(as.Codecs)
+	}
+	if t.FrameRate == "" {
+		t.FrameRate = str(as.FrameRate)
+	}
+	var ok bool
+	if t.Kind, ok = dashKind(str(as.ContentType), t.MimeType, t.Codecs); !ok {
+		return nil
+	}
+
+	if rep.Bandwidth != nil {
+		t.Bandwidth = int(*rep.Bandwidth)
+	}
+	if rep.Width != nil && rep.Height != nil {
+		t.Width, t.Height = int(*rep.Width), int(*rep.Height)
+	} else {
+		t.Width, t.Height = atoi(str(as.Width)), atoi(str(as.Height))
+	}
+	if rep.AudioChannelConfiguration != nil {
+		t.Channels = str(rep.AudioChannelConfiguration.Value)
+	} else if len(as.AudioChannelConfiguration) > 0 {
+		t.Channels = str(as.AudioChannelConfiguration[0].Value)
+	}
+
+	for _, role := range as.Roles {
+		value := str(role.Value)
+		t.Roles = append(t.Roles, value)
+		switch value {
+		case "main":
+			t.Default = true
+		case "caption":
+			t.SDH = t.Kind == Text
+		case "description":
+			t.AudioDescription = t.Kind == Audio
+		}
+	}
+	for _, a := range as.AccessibilityElems {
+		if str(a.SchemeIdUri) != audioPurposeScheme {
+			continue
+		}
+		switch str(a.Value) {
+		case "1":
+			t.AudioDescription = t.Kind == Audio
+		case "2":
+			t.SDH = t.Kind == Text
+		}
+	}
+	if t.Kind == Audio && strings.EqualFold(t.Lang, "qad") {
+		t.AudioDescription = true
+	}
+	return t
+}
+
+// segmentTemplate merges the templates of the representation, adaptation
+// set and period, the most specific values win.
+func segmentTemplate(period *mpd.Period, as *mpd.AdaptationSet, rep *mpd.Representation) *mpd.SegmentTemplate {
+	var merged *mpd.SegmentTemplate
+	for _, t := range []*mpd.SegmentTemplate{period.SegmentTemplate, as.SegmentTemplate, rep.SegmentTemplate} {
+		if t == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &mpd.SegmentTemplate{}
+		}
+		if t.SegmentTimeline != nil {
+			merged.SegmentTimeline = t.SegmentTimeline
+		}
+		if t.Duration != nil {
+			merged.Duration = t.Duration
+		}
+		if t.Initialization != nil {
+			merged.Initialization = t.Initialization
+		}
+		if t.Media != nil {
+			merged.Media = t.Media
+		}
+		if t.StartNumber != nil {
+			merged.StartNumber = t.StartNumber
+		}
+		if t.Timescale != nil {
+			merged.Timescale = t.Timescale
+		}
+	}
+	return merged
+}
+
+func dashSegments(base string, period *mpd.Period, as *mpd.AdaptationSet, rep *mpd.Representation, periodDuration time.Duration) (*Segment, []Segment, error) {
+	if tmpl := segmentTemplate(period, as, rep); tmpl != nil && tmpl.Media != nil {
+		return templateSegments(base, tmpl, rep, periodDuration)
+	}
+
+	list := rep.SegmentList
+	if list == nil {
+		list = as.SegmentList
+	}
+	if list != nil && len(list.SegmentURLs) > 0 {
+		var init *Segment
+		if list.Initialization != nil {
+			init = &Segment{URL: resolve(base, str(list.Initialization.SourceURL)), Range: str(list.Initialization.Range)}
+		}
+		timescale, duration := uint32(1), uint32(0)
+		if list.Timescale != nil && *list.Timescale > 0 {
+			timescale = *list.Timescale
+		}
+		if list.Duration != nil {
+			duration = *list.Duration
+		}
+		segments := make([]Segment, 0, len(list.SegmentURLs))
+		for _, u := range list.SegmentURLs {
+			segments = append(segments, Segment{
+				URL:      resolve(base, str(u.Media)),
+				Range:    str(u.MediaRange),
+				Duration: time.Duration(float64(duration) / float64(timescale) * float64(time.Second)),
+			})
+		}
+		return init, segments, nil
+	}
+
+	// single file, optionally indexed with a SegmentBase
+	return nil, []Segment{{URL: base, Duration: periodDuration}}, nil
+}
+
+var templateIdentifierRegexp = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(%0(\d+)d)?\$`)
+
+// expandTemplate replaces the identifiers of a segment template.
+// See ISO 23009-1 5.3.9.4.4
+func expandTemplate(tmpl string, rep *mpd.Representation, number, t uint64) string {
+	expanded := templateIdentifierRegexp.ReplaceAllStringFunc(tmpl, func(id string) string {
+		m := templateIdentifierRegexp.FindStringSubmatch(id)
+		var n uint64
+		switch m[1] {
+		case "RepresentationID":
+			return str(rep.ID)
+		case "Number":
+			n = number
+		case "Time":
+			n = t
+		case "Bandwidth":
+			if rep.Bandwidth != nil {
+				n = uint64(*rep.Bandwidth)
+			}
+		}
+		if m[3] != "" {
+			width, _ := strconv.Atoi(m[3])
+			return fmt.Sprintf("%0*d", width, n)
+		}
+		return strconv.FormatUint(n, 10)
+	})
+	return strings.ReplaceAll(expanded, "$$", "$")
+}
+
+func templateSegments(base string, tmpl *mpd.SegmentTemplate, rep *mpd.Representation, periodDuration time.Duration) (*Segment, []Segment, error) {
+	timescale := int64(1)
+	if tmpl.Timescale != nil && *tmpl.Timescale > 0 {
+		timescale = *tmpl.Timescale
+	}
+	number := uint64(1)
+	if tmpl.StartNumber != nil {
+		number = uint64(*tmpl.StartNumber)
+	}
+	toDuration := func(d uint64) time.Duration {
+		return time.Duration(float64(d) / float64(timescale) * float64(time.Second))
+	}
+
+	var init *Segment
+	if tmpl.Initialization != nil {
+		init = &Segment{URL: resolve(base, expandTemplate(*tmpl.Initialization, rep, 0, 0))}
+	}
+
+	var segments []Segment
+	add := func(t, d uint64) {
+		segments = append(segments, Segment{
+			URL:      resolve(base, expandTemplate(*tmpl.Media, rep, number, t)),
+			Duration: toDuration(d),
+		})
+		number++
+	}
+
+	if tmpl.SegmentTimeline != nil {
+		end := uint64(periodDuration.Seconds() * float64(timescale))
+		var t uint64
+		for _, s := range tmpl.SegmentTimeline.Segments {
+			if s.StartTime != nil {
+				t = *s.StartTime
+			}
+			repeat := 0
+			if s.RepeatCount != nil {
+				repeat = *s.RepeatCount
+			}
+			if repeat < 0 {
+				// repeated until the end of the period
+				if end == 0 || s.Duration == 0 {
+					return nil, nil, fmt.Errorf("open ended segment timeline without a period duration")
+				}
+				repeat = 0
+				// a malformed timeline can already be past the end
+				if t < end {
+					repeat = int((end-t+s.Duration-1)/s.Duration) - 1
+				}
+			}
+			for i := 0; i <= repeat; i++ {
+				add(t, s.Duration)
+				t += s.Duration
+			}
+		}
+		return init, segments, nil
+	}
+
+	if tmpl.Duration == nil || *tmpl.Duration <= 0 {
+		return nil, nil, fmt.Errorf("segment template without a duration or a timeline")
+	}
+	if periodDuration == 0 {
+		return nil, nil, fmt.Errorf("segment template without a period duration")
+	}
+	d := uint64(*tmpl.Duration)
+	count := int(math.Ceil(periodDuration.Seconds() * float64(timescale) / float64(d)))
+	for i := 0; i < count; i++ {
+		add(uint64(i)*d, d)
+	}
+	return init, segments, nil
+}
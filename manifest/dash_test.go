@@ -0,0 +1,166 @@
+package manifest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mattetti/go-dash/mpd"
+)
+
+func parseDASHFixture(t *testing.T, path, baseURL string) *Manifest {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := ParseDASH(f, baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestParseDASHTimeline(t *testing.T) {
+	m := parseDASHFixture(t, "testdata/timeline.mpd", "https://cdn.example/path/manifest.mpd?hdnea=token")
+	if m.Format != DASH {
+		t.Errorf("expected the DASH format, got %s", m.Format)
+	}
+	if expected := time.Minute + 500*time.Millisecond; m.Duration != expected {
+		t.Errorf("expected a duration of %s, got %s", expected, m.Duration)
+	}
+	// the thumbnails are left out
+	if len(m.Tracks) != 6 {
+		t.Fatalf("expected 6 tracks, got %d", len(m.Tracks))
+	}
+
+	videos := m.TracksOf(Video)
+	if len(videos) != 2 {
+		t.Fatalf("expected 2 video tracks, got %d", len(videos))
+	}
+	hd := videos[1]
+	if hd.Width != 1280 || hd.Height != 720 || hd.Bandwidth != 2000000 || hd.Codecs != "avc1.64001F" || hd.FrameRate != "25" {
+		t.Errorf("unexpected video track: %s", hd)
+	}
+	if hd.Init == nil || hd.Init.URL != "https://cdn.example/path/dash/video=2000000/init.mp4" {
+		t.Errorf("unexpected init segment %+v", hd.Init)
+	}
+	// 60.5s in 10s segments, repeated until the end of the period
+	if len(hd.Segments) != 7 {
+		t.Fatalf("expected 7 video segments, got %d", len(hd.Segments))
+	}
+	if expected := "https://cdn.example/path/dash/video=2000000/seg-00007.m4s"; hd.Segments[6].URL != expected {
+		t.Errorf("expected the last segment to be %s, got %s", expected, hd.Segments[6].URL)
+	}
+
+	audios := m.TracksOf(Audio)
+	if len(audios) != 2 {
+		t.Fatalf("expected 2 audio tracks, got %d", len(audios))
+	}
+	main, ad := audios[0], audios[1]
+	if main.Lang != "fr" || !main.Default || main.AudioDescription || main.Channels != "2" {
+		t.Errorf("unexpected main audio track: %s", main)
+	}
+	if !ad.AudioDescription || ad.Default {
+		t.Errorf("expected the qad track to be the audio description: %s", ad)
+	}
+	if len(main.Segments) != 7 {
+		t.Fatalf("expected 7 audio segments, got %d", len(main.Segments))
+	}
+	last := main.Segments[6]
+	if expected := "https://cdn.example/path/dash/audio_fra=96000/2880000.m4s"; last.URL != expected {
+		t.Errorf("expected the last segment to be %s, got %s", expected, last.URL)
+	}
+	if last.Duration != 500*time.Millisecond {
+		t.Errorf("expected the last segment to last 500ms, got %s", last.Duration)
+	}
+
+	texts := m.TracksOf(Text)
+	if len(texts) != 2 {
+		t.Fatalf("expected 2 text tracks, got %d", len(texts))
+	}
+	if texts[0].SDH || texts[0].Codecs != "stpp" || len(texts[0].Segments) != 3 {
+		t.Errorf("unexpected stpp track: %s with %d segments", texts[0], len(texts[0].Segments))
+	}
+	sdh := texts[1]
+	if !sdh.SDH {
+		t.Errorf("expected the caption track to be flagged SDH: %s", sdh)
+	}
+	if len(sdh.Segments) != 1 || sdh.Segments[0].URL != "https://subs.example/sdh.vtt" || sdh.Init != nil {
+		t.Errorf("expected the vtt file to be a single segment, got %+v", sdh.Segments)
+	}
+}
+
+func TestTemplateSegmentsPastPeriodEnd(t *testing.T) {
+	u64 := func(v uint64) *uint64 { return &v }
+	i64 := func(v int64) *int64 { return &v }
+	integer := func(v int) *int { return &v }
+	media := "seg-$Time$.m4s"
+	tmpl := &mpd.SegmentTemplate{
+		Media:     &media,
+		Timescale: i64(1),
+		SegmentTimeline: &mpd.SegmentTimeline{Segments: []*mpd.SegmentTimelineSegment{
+			{StartTime: u64(0), Duration: 4, RepeatCount: integer(3)},
+			// starts after the end of the 10s period
+			{Duration: 4, RepeatCount: integer(-1)},
+		}},
+	}
+	_, segments, err := templateSegments("https://cdn.example/", tmpl, &mpd.Representation{}, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 5 {
+		t.Fatalf("expected 5 segments, got %d", len(segments))
+	}
+	if expected := "https://cdn.example/seg-16.m4s"; segments[4].URL != expected {
+		t.Errorf("expected the last segment to be %s, got %s", expected, segments[4].URL)
+	}
+}
+
+func TestParseDASHTemplateAndList(t *testing.T) {
+	m := parseDASHFixture(t, "testdata/template.mpd", "https://other.example/manifest.mpd")
+	if len(m.Tracks) != 2 {
+		t.Fatalf("expected 2 tracks, got %d", len(m.Tracks))
+	}
+	video := m.Tracks[0]
+	if video.Kind != Video || !video.Protected {
+		t.Errorf("expected a protected video track: %s", video)
+	}
+	if len(video.Segments) != 3 {
+		t.Fatalf("expected 3 segments of 4s for 10s, got %d", len(video.Segments))
+	}
+	if expected := "https://cdn.example/video/5000000/0.m4s$"; video.Segments[0].URL != expected {
+		t.Errorf("expected the first segment to be %s, got %s", expected, video.Segments[0].URL)
+	}
+
+	audio := m.Tracks[1]
+	if audio.Kind != Audio || audio.Lang != "en" {
+		t.Errorf("unexpected audio track: %s", audio)
+	}
+	if audio.Init == nil || audio.Init.URL != "https://cdn.example/video/audio.mp4" || audio.Init.Range != "0-799" {
+		t.Errorf("unexpected init segment %+v", audio.Init)
+	}
+	if len(audio.Segments) != 2 || audio.Segments[1].Range != "2000-2999" || audio.Segments[1].Duration != 5*time.Second {
+		t.Errorf("unexpected segments %+v", audio.Segments)
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	id, bandwidth := "v1", int64(5000000)
+	rep := &mpd.Representation{ID: &id, Bandwidth: &bandwidth}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"$RepresentationID$/$Number$.m4s", "v1/12.m4s"},
+		{"$Number%05d$.m4s", "00012.m4s"},
+		{"$Time$-$Bandwidth$", "3600-5000000"},
+		{"100$$", "100$"},
+	}
+	for _, tt := range tests {
+		if got := expandTemplate(tt.template, rep, 12, 3600); got != tt.expected {
+			t.Errorf("expanding %s, got %s, expected %s", tt.template, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// variantID identifies a variant by its bandwidth and resolution, the order of
+// the variants can change when the manifest is signed again. The name of the
+// playlist tells apart the variants sharing them.
+func variantID(m *Manifest, attrs map[string]string, uri string) string {
+	id := "video-" + attrs["BANDWIDTH"]
+	if res := attrs["RESOLUTION"]; res != "" {
+		id += "-" + res
+	}
+	for _, t := range m.Tracks {
+		if t.ID == id {
+			name, _, _ := strings.Cut(path.Base(uri), "?")
+			return id + "-" + name
+		}
+	}
+	return id
+}
+
+// HLS characteristics flagging the accessibility renditions.
+const (
+	characteristicSDH              = "public.accessibility.describes-music-and-sound"
+	characteristicAudioDescription = "public.accessibility.describes-video"
+)
+
+// MediaPlaylist is a parsed HLS media playlist.
+type MediaPlaylist struct {
+	Init     *Segment
+	Segments []Segment
+	Key      *Key
+}
+
+// ParseHLS parses an HLS playlist. The variants and renditions of a master
+// playlist are listed without their segments, see LoadSegments. A media
+// playlist is returned as a single track with its segments.
+func ParseHLS(r io.Reader, baseURL string) (*Manifest, error) {
+	lines, err := playlistLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Format: HLS, URL: baseURL}
+	isMaster := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") || strings.HasPrefix(line, "#EXT-X-MEDIA:") {
+			isMaster = true
+			break
+		}
+	}
+	if !isMaster {
+		media, err := parseMediaLines(lines, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		t := &Track{Kind: Video, Init: media.Init, Segments: media.Segments, Key: media.Key}
+		for _, s := range media.Segments {
+			m.Duration += s.Duration
+		}
+		m.Tracks = append(m.Tracks, t)
+		return m, nil
+	}
+
+	// the session key can be listed before or after the variants, it
+	// protects all of them
+	protected := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			uri := ""
+			for _, next := range lines[i+1:] {
+				if !strings.HasPrefix(next, "#") {
+					uri = next
+					break
+				}
+			}
+			if uri == "" {
+				return nil, fmt.Errorf("variant without a playlist URL")
+			}
+			t := &Track{
+				ID:          variantID(m, attrs, uri),
+				Kind:        Video,
+				Codecs:      attrs["CODECS"],
+				Bandwidth:   atoi(attrs["BANDWIDTH"]),
+				FrameRate:   attrs["FRAME-RATE"],
+				PlaylistURL: resolve(baseURL, uri),
+				AudioGroup:  attrs["AUDIO"],
+			}
+			if w, h, ok := strings.Cut(attrs["RESOLUTION"], "x"); ok {
+				t.Width, t.Height = atoi(w), atoi(h)
+			}
+			if t.Width == 0 && t.Height == 0 && isAudioOnly(t.Codecs) {
+				t.Kind = Audio
+			}
+			m.Tracks = append(m.Tracks, t)
+
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			var kind Kind
+			switch attrs["TYPE"] {
+			case "AUDIO":
+				kind = Audio
+			case "SUBTITLES":
+				kind = Text
+			default:
+				continue
+			}
+			// the audio renditions without URI are muxed in the variants,
+			// they're kept to be picked like the other languages
+			muxed := attrs["URI"] == ""
+			if muxed && kind != Audio {
+				continue
+			}
+			t := &Track{
+				ID:       attrs["GROUP-ID"] + "/" + attrs["NAME"],
+				Kind:     kind,
+				Lang:     attrs["LANGUAGE"],
+				Label:    attrs["NAME"],
+				Default:  attrs["DEFAULT"] == "YES",
+				Channels: attrs["CHANNELS"],
+				Group:    attrs["GROUP-ID"],
+				Muxed:    muxed,
+			}
+			if !muxed {
+				t.PlaylistURL = resolve(baseURL, attrs["URI"])
+			}
+			if kind == Text {
+				t.MimeType = "text/vtt"
+			}
+			if c := attrs["CHARACTERISTICS"]; c != "" {
+				t.Roles = strings.Split(c, ",")
+				for _, role := range t.Roles {
+					switch role {
+					case characteristicSDH:
+						t.SDH = kind == Text
+					case characteristicAudioDescription:
+						t.AudioDescription = kind == Audio
+					}
+				}
+			}
+			if kind == Audio && strings.EqualFold(t.Lang, "qad") {
+				t.AudioDescription = true
+			}
+			m.Tracks = append(m.Tracks, t)
+
+		case strings.HasPrefix(line, "#EXT-X-SESSION-KEY:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-SESSION-KEY:"))
+			if attrs["METHOD"] == "SAMPLE-AES" || attrs["KEYFORMAT"] != "" {
+				protected = true
+			}
+		}
+	}
+	if protected {
+		for _, t := range m.Tracks {
+			t.Protected = true
+		}
+	}
+	return m, nil
+}
+
+// ParseHLSMedia parses an HLS media playlist, baseURL is the URL of the
+// playlist.
+func ParseHLSMedia(r io.Reader, baseURL string) (*MediaPlaylist, error) {
+	lines, err := playlistLines(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseMediaLines(lines, baseURL)
+}
+
+func parseMediaLines(lines []string, baseURL string) (*MediaPlaylist, error) {
+	media := &MediaPlaylist{}
+	var (
+		duration  time.Duration
+		byteRange string
+		// offset of the next segment in a file split in byte ranges
+		nextOffset int64
+		lastURL    string
+		sequence   int64
+	)
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad segment duration %q", line)
+			}
+			duration = time.Duration(seconds * float64(time.Second))
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			byteRange = strings.TrimPrefix(line, "#EXT-X-BYTERANGE:")
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			media.Init = &Segment{URL: resolve(baseURL, attrs["URI"])}
+			if br := attrs["BYTERANGE"]; br != "" {
+				media.Init.Range, _ = rangeHeader(br, 0)
+			}
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			if attrs["METHOD"] == "" || attrs["METHOD"] == "NONE" {
+				media.Key = nil
+				continue
+			}
+			if attrs["METHOD"] != "AES-128" {
+				return nil, fmt.Errorf("unsupported encryption method %s", attrs["METHOD"])
+			}
+			key := &Key{Method: attrs["METHOD"], URI: resolve(baseURL, attrs["URI"])}
+			if iv := attrs["IV"]; iv != "" {
+				b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+				if err != nil {
+					return nil, fmt.Errorf("bad encryption IV %q", iv)
+				}
+				key.IV = b
+			}
+			if media.Key != nil && len(media.Segments) > 0 && media.Key.URI != key.URI {
+				return nil, fmt.Errorf("key rotation isn't supported")
+			}
+			media.Key = key
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			s := Segment{URL: resolve(baseURL, line), Duration: duration, Sequence: sequence}
+			if byteRange != "" {
+				if s.URL != lastURL {
+					nextOffset = 0
+				}
+				var err error
+				if s.Range, nextOffset, err = rangeHeaderNext(byteRange, nextOffset); err != nil {
+					return nil, err
+				}
+			}
+			media.Segments = append(media.Segments, s)
+			lastURL, duration, byteRange = s.URL, 0, ""
+			sequence++
+		}
+	}
+	return media, nil
+}
+
+// rangeHeader converts an HLS byte range, "length[@offset]", to the
+// "start-end" format of the HTTP Range header.
+func rangeHeader(br string, offset int64) (string, error) {
+	r, _, err := rangeHeaderNext(br, offset)
+	return r, err
+}
+
+func rangeHeaderNext(br string, offset int64) (string, int64, error) {
+	length, start, found := strings.Cut(br, "@")
+	n, err := strconv.ParseInt(length, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad byte range %q", br)
+	}
+	if found {
+		if offset, err = strconv.ParseInt(start, 10, 64); err != nil {
+			return "", 0, fmt.Errorf("bad byte range %q", br)
+		}
+	}
+	return fmt.Sprintf("%d-%d", offset, offset+n-1), offset + n, nil
+}
+
+func isAudioOnly(codecs string) bool {
+	if codecs == "" {
+		return false
+	}
+	for _, c := range strings.Split(codecs, ",") {
+		if kind, ok := dashKind("", "", strings.TrimSpace(c)); !ok || kind != Audio {
+			return false
+		}
+	}
+	return true
+}
+
+func playlistLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "#EXTM3U") {
+		return nil, fmt.Errorf("not an HLS playlist")
+	}
+	return lines, nil
+}
+
+// parseAttributes parses an HLS attribute list: KEY=VALUE,KEY="QUOTED,VALUE"
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for s != "" {
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		key = strings.TrimSpace(key)
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[key] = value
+		s = rest
+	}
+	return attrs
+}
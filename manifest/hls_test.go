@@ -0,0 +1,184 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHLSMaster(t *testing.T) {
+	f, err := os.Open("testdata/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := ParseHLS(f, "https://cdn.example/hls/master.m3u8?token=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Format != HLS {
+		t.Errorf("expected the HLS format, got %s", m.Format)
+	}
+
+	videos := m.TracksOf(Video)
+	if len(videos) != 2 {
+		t.Fatalf("expected 2 video variants, got %d", len(videos))
+	}
+	hd := videos[1]
+	if hd.Width != 1280 || hd.Height != 720 || hd.Bandwidth != 2436000 || hd.FrameRate != "25" {
+		t.Errorf("unexpected variant: %s", hd)
+	}
+	if hd.PlaylistURL != "https://cdn.example/hls/video_2000.m3u8" {
+		t.Errorf("unexpected playlist URL %s", hd.PlaylistURL)
+	}
+
+	audios := m.TracksOf(Audio)
+	if len(audios) != 3 {
+		t.Fatalf("expected 2 audio renditions and an audio only variant, got %d", len(audios))
+	}
+	if a := audios[0]; a.Lang != "fr" || !a.Default || a.AudioDescription || a.Label != "Français" {
+		t.Errorf("unexpected audio rendition: %s", a)
+	}
+	if a := audios[1]; !a.AudioDescription {
+		t.Errorf("expected the audio description to be flagged: %s", a)
+	}
+
+	texts := m.TracksOf(Text)
+	if len(texts) != 2 {
+		t.Fatalf("expected 2 subtitles renditions, got %d", len(texts))
+	}
+	if texts[0].SDH || !texts[1].SDH {
+		t.Errorf("expected the second subtitles to be SDH: %s / %s", texts[0], texts[1])
+	}
+	if texts[1].PlaylistURL != "https://subs.example/sdh.m3u8" {
+		t.Errorf("unexpected playlist URL %s", texts[1].PlaylistURL)
+	}
+}
+
+func TestParseHLSVariantIDs(t *testing.T) {
+	variants := []string{
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nvideo_800.m3u8?token=a",
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720\nvideo_2000.m3u8?token=a",
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720\nvideo_2000_hevc.m3u8?token=a",
+	}
+	ids := func(variants ...string) map[string]string {
+		m, err := ParseHLS(strings.NewReader("#EXTM3U\n"+strings.Join(variants, "\n")), "https://cdn.example/hls/master.m3u8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		byURL := map[string]string{}
+		for _, v := range m.Tracks {
+			byURL[v.PlaylistURL] = v.ID
+		}
+		return byURL
+	}
+	first := ids(variants...)
+	if id := first["https://cdn.example/hls/video_800.m3u8?token=a"]; id != "video-800000-640x360" {
+		t.Errorf("unexpected variant ID %s", id)
+	}
+	if len(first) != 3 || first["https://cdn.example/hls/video_2000.m3u8?token=a"] == first["https://cdn.example/hls/video_2000_hevc.m3u8?token=a"] {
+		t.Errorf("expected a distinct ID per variant, got %v", first)
+	}
+	// the variants keep their ID when the manifest lists them in another
+	// order
+	if reordered := ids(variants[1], variants[0], variants[2]); !reflect.DeepEqual(reordered, first) {
+		t.Errorf("got %v, expected %v", reordered, first)
+	}
+}
+
+func TestParseHLSSessionKey(t *testing.T) {
+	key := `#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery"`
+	variant := "#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nvideo_800.m3u8"
+	for _, playlist := range []string{key + "\n" + variant, variant + "\n" + key} {
+		m, err := ParseHLS(strings.NewReader("#EXTM3U\n"+playlist), "https://cdn.example/hls/master.m3u8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, track := range m.Tracks {
+			if !track.Protected {
+				t.Errorf("expected track %s to be protected", track.ID)
+			}
+		}
+		if _, err := m.Select(Preferences{}); !errors.Is(err, ErrProtected) {
+			t.Errorf("expected ErrProtected, got %v", err)
+		}
+	}
+}
+
+// muxedAudioMaster is a master playlist whose default audio is muxed in the
+// variants.
+const muxedAudioMaster = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",LANGUAGE="fr",NAME="Français",DEFAULT=YES,AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",LANGUAGE="en",NAME="English",DEFAULT=NO,AUTOSELECT=YES,URI="audio_eng.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720,AUDIO="audio"
+video_2000.m3u8
+`
+
+func TestParseHLSMuxedAudio(t *testing.T) {
+	m, err := ParseHLS(strings.NewReader(muxedAudioMaster), "https://cdn.example/hls/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audios := m.TracksOf(Audio)
+	if len(audios) != 2 {
+		t.Fatalf("expected 2 audio tracks, got %d", len(audios))
+	}
+	if !audios[0].Muxed || audios[0].PlaylistURL != "" {
+		t.Errorf("expected the default audio to be muxed, got %+v", audios[0])
+	}
+	if audios[1].Muxed {
+		t.Errorf("expected the english audio to have its own playlist")
+	}
+
+	sel, err := m.Select(Preferences{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.Audio) != 1 || sel.Audio[0] != audios[0] {
+		t.Errorf("expected the muxed default audio, got %v", sel.Audio)
+	}
+	sel, err = m.Select(Preferences{AudioLangs: []string{"eng"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.Audio) != 1 || sel.Audio[0] != audios[1] {
+		t.Errorf("expected the english audio, got %v", sel.Audio)
+	}
+}
+
+func TestParseHLSMedia(t *testing.T) {
+	f, err := os.Open("testdata/media.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	media, err := ParseHLSMedia(f, "https://cdn.example/hls/video_400.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (&Segment{URL: "https://cdn.example/hls/init.mp4", Range: "0-719"}); !reflect.DeepEqual(media.Init, expected) {
+		t.Errorf("got init %+v, expected %+v", media.Init, expected)
+	}
+	if media.Key == nil || media.Key.Method != "AES-128" || media.Key.URI != "https://keys.example/key?id=1" || media.Key.IV != nil {
+		t.Errorf("unexpected key %+v", media.Key)
+	}
+	expected := []Segment{
+		{URL: "https://cdn.example/hls/media.mp4", Range: "720-1719", Duration: 10 * time.Second, Sequence: 3},
+		{URL: "https://cdn.example/hls/media.mp4", Range: "1720-3219", Duration: 10 * time.Second, Sequence: 4},
+		{URL: "https://cdn.example/hls/segment-3.ts", Duration: 4500 * time.Millisecond, Sequence: 5},
+	}
+	if !reflect.DeepEqual(media.Segments, expected) {
+		t.Errorf("got %+v, expected %+v", media.Segments, expected)
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	got := parseAttributes(`TYPE=AUDIO,NAME="Français, VO",DEFAULT=YES,URI="a.m3u8"`)
+	expected := map[string]string{"TYPE": "AUDIO", "NAME": "Français, VO", "DEFAULT": "YES", "URI": "a.m3u8"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,59 @@
+package manifest
+
+import "strings"
+
+// langCodes maps the language codes and names found in the france.tv
+// manifests to their ISO 639-2/T code.
+var langCodes = map[string]string{
+	"fr": "fra", "fre": "fra", "fra": "fra", "french": "fra", "français": "fra", "francais": "fra",
+	"en": "eng", "eng": "eng", "english": "eng", "anglais": "eng",
+	"de": "deu", "ger": "deu", "deu": "deu", "german": "deu", "allemand": "deu",
+	"es": "spa", "spa": "spa", "spanish": "spa", "espagnol": "spa",
+	"it": "ita", "ita": "ita", "italian": "ita", "italien": "ita",
+	"pt": "por", "por": "por", "portuguese": "por", "portugais": "por",
+	"nl": "nld", "dut": "nld", "nld": "nld",
+	"ar": "ara", "ara": "ara",
+	// private use codes: audio description and original version
+	"qad": "qad", "qaa": "qaa", "qtz": "qtz",
+	"und": "und", "": "und",
+}
+
+// NormalizeLang returns the ISO 639-2/T code of a language code or name,
+// "fr", "fre" and "fra" all become "fra". Unknown languages are returned lower
+// cased and the region subtags are dropped: "fr-FR" becomes "fra".
+func NormalizeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	if code, ok := langCodes[lang]; ok {
+		return code
+	}
+	return lang
+}
+
+// MatchLang reports whether the track language is one of the wanted ones.
+// All the languages match when wanted is empty.
+func MatchLang(lang string, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	lang = NormalizeLang(lang)
+	for _, w := range wanted {
+		if NormalizeLang(w) == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLangs splits a comma separated list of languages.
+func ParseLangs(s string) []string {
+	var langs []string
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			langs = append(langs, l)
+		}
+	}
+	return langs
+}
@@ -0,0 +1,257 @@
+// Package manifest parses the DASH (MPD) and HLS (m3u8) manifests of the
+// france.tv streams into a list of tracks with the information needed to
+// pick and download them.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kind is the type of content of a track.
+type Kind int
+
+const (
+	Video Kind = iota
+	Audio
+	Text
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Video:
+		return "video"
+	case Audio:
+		return "audio"
+	case Text:
+		return "text"
+	default:
+		return "unknown"
+	}
+}
+
+// Format is the streaming format of a manifest.
+type Format string
+
+const (
+	DASH Format = "dash"
+	HLS  Format = "hls"
+)
+
+// ErrUnknownFormat is returned when a manifest is neither DASH nor HLS.
+var ErrUnknownFormat = errors.New("unknown manifest format")
+
+// Manifest is a parsed stream manifest.
+type Manifest struct {
+	Format Format
+	// URL is the URL the manifest was fetched from, after the redirects.
+	URL      string
+	Duration time.Duration
+	Tracks   []*Track
+}
+
+// Segment is a piece of a track to download. The segments are concatenated
+// in order to rebuild the track.
+type Segment struct {
+	URL string
+	// Range is the byte range to request, "start-end", if the segment is part
+	// of a larger file.
+	Range    string
+	Duration time.Duration
+	// Sequence is the HLS media sequence number of the segment, used as the
+	// IV of the encrypted segments when the key doesn't set one.
+	Sequence int64
+}
+
+// Key is the encryption key of HLS segments.
+type Key struct {
+	Method string
+	URI    string
+	// IV is nil when the sequence number of the segments is used instead.
+	IV []byte
+}
+
+// Track is a representation of a DASH adaptation set or an HLS variant or
+// rendition.
+type Track struct {
+	ID        string
+	Kind      Kind
+	Lang      string
+	Label     string
+	MimeType  string
+	Codecs    string
+	Bandwidth int
+	Width     int
+	Height    int
+	FrameRate string
+	Channels  string
+	// Roles lists the DASH roles (main, caption, subtitle, description...)
+	// or the HLS characteristics of the track.
+	Roles []string
+	// SDH is set for the subtitles for the deaf and hard of hearing.
+	SDH bool
+	// AudioDescription is set for the audio tracks describing the video for
+	// the visually impaired.
+	AudioDescription bool
+	Default          bool
+	// Protected is set for the DRM protected tracks, they can't be
+	// downloaded.
+	Protected bool
+
+	// Init is the initialization segment, if any.
+	Init     *Segment
+	Segments []Segment
+	// Key is set when the HLS segments are encrypted.
+	Key *Key
+	// PlaylistURL is the media playlist of an HLS track, its segments are
+	// loaded by LoadSegments.
+	PlaylistURL string
+	// Group is the group of an HLS rendition and AudioGroup the group of
+	// the audio renditions played with an HLS variant. A variant without
+	// AudioGroup has its audio muxed in.
+	Group      string
+	AudioGroup string
+	// Muxed is set for the HLS audio renditions without playlist, they're
+	// muxed in the variants of their group and downloaded with the video.
+	Muxed bool
+}
+
+// String describes the track on one line.
+func (t *Track) String() string {
+	var parts []string
+	add := func(format string, args ...interface{}) { parts = append(parts, fmt.Sprintf(format, args...)) }
+	add("%-5s", t.Kind)
+	if t.ID != "" {
+		add("id=%s", t.ID)
+	}
+	if t.Width > 0 && t.Height > 0 {
+		add("%dx%d", t.Width, t.Height)
+	}
+	if t.FrameRate != "" {
+		add("%sfps", t.FrameRate)
+	}
+	if t.Bandwidth > 0 {
+		add("%dkbps", t.Bandwidth/1000)
+	}
+	if t.Codecs != "" {
+		add("codecs=%s", t.Codecs)
+	} else if t.MimeType != "" {
+		add("mime=%s", t.MimeType)
+	}
+	if t.Channels != "" {
+		add("channels=%s", t.Channels)
+	}
+	if t.Lang != "" {
+		add("lang=%s", t.Lang)
+	}
+	if t.Label != "" {
+		add("%q", t.Label)
+	}
+	if t.SDH {
+		add("SDH")
+	}
+	if t.AudioDescription {
+		add("audio-description")
+	}
+	if t.Protected {
+		add("DRM")
+	}
+	if t.Muxed {
+		add("muxed")
+	}
+	return strings.Join(parts, " ")
+}
+
+// TracksOf returns the tracks of the given kind.
+func (m *Manifest) TracksOf(kind Kind) []*Track {
+	var tracks []*Track
+	for _, t := range m.Tracks {
+		if t.Kind == kind {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// Fetch downloads and parses the manifest at manifestURL. The format is
+// detected from the content.
+func Fetch(client *http.Client, manifestURL string) (*Manifest, error) {
+	body, finalURL, err := get(client, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(body, finalURL)
+}
+
+// Parse parses a DASH or HLS manifest, baseURL is used to resolve the
+// relative URLs.
+func Parse(body []byte, baseURL string) (*Manifest, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		return ParseHLS(bytes.NewReader(trimmed), baseURL)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return ParseDASH(bytes.NewReader(trimmed), baseURL)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// LoadSegments fetches the media playlist of an HLS track to list its
+// segments. It does nothing for the tracks already listing their segments.
+func LoadSegments(client *http.Client, t *Track) error {
+	if t.PlaylistURL == "" || len(t.Segments) > 0 {
+		return nil
+	}
+	body, finalURL, err := get(client, t.PlaylistURL)
+	if err != nil {
+		return err
+	}
+	media, err := ParseHLSMedia(bytes.NewReader(body), finalURL)
+	if err != nil {
+		return err
+	}
+	t.Init, t.Segments, t.Key = media.Init, media.Segments, media.Key
+	return nil
+}
+
+func get(client *http.Client, u string) ([]byte, string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(u)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("bad status code fetching %s: %s", u, res.Status)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, res.Request.URL.String(), nil
+}
+
+// resolve returns ref relative to base, ref is returned as is if it can't be
+// parsed.
+func resolve(base, ref string) string {
+	if ref == "" {
+		return base
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchAndLoadSegments(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "\xef\xbb\xbf#EXTM3U\n#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",LANGUAGE=\"fr\",NAME=\"fr\",URI=\"subs/fr.m3u8\"\n#EXT-X-STREAM-INF:BANDWIDTH=1000,RESOLUTION=640x360\nvideo.m3u8\n")
+	})
+	mux.HandleFunc("/subs/fr.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXTINF:10,\n0.vtt\n#EXTINF:10,\n1.vtt\n#EXT-X-ENDLIST\n")
+	})
+
+	m, err := Fetch(srv.Client(), srv.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := m.TracksOf(Text)
+	if len(texts) != 1 {
+		t.Fatalf("expected 1 subtitles track, got %d", len(texts))
+	}
+	if err := LoadSegments(srv.Client(), texts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(texts[0].Segments) != 2 || texts[0].Segments[1].URL != srv.URL+"/subs/1.vtt" {
+		t.Errorf("unexpected segments %+v", texts[0].Segments)
+	}
+
+	if _, err := Parse([]byte("{}"), srv.URL); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestNormalizeLang(t *testing.T) {
+	tests := map[string]string{
+		"fr":    "fra",
+		"fre":   "fra",
+		"FR-fr": "fra",
+		"en":    "eng",
+		"qad":   "qad",
+		"":      "und",
+		"xyz":   "xyz",
+	}
+	for lang, expected := range tests {
+		if got := NormalizeLang(lang); got != expected {
+			t.Errorf("%q: got %q, expected %q", lang, got, expected)
+		}
+	}
+	if !MatchLang("fr", []string{"eng", "fra"}) || MatchLang("qad", []string{"fra"}) || !MatchLang("de", nil) {
+		t.Error("unexpected language match")
+	}
+}
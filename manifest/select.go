@@ -0,0 +1,300 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoTrack is returned when no track matches the preferences.
+	ErrNoTrack = errors.New("no track matching the preferences")
+	// ErrProtected is returned when all the video tracks are protected by a
+	// DRM.
+	ErrProtected = errors.New("the video is protected by a DRM")
+)
+
+// Quality is the video quality to pick.
+type Quality struct {
+	// Worst picks the lowest quality instead of the best one.
+	Worst bool
+	// MaxHeight and MaxBitrate, in bits per second, cap the quality, 0 means
+	// no limit.
+	MaxHeight  int
+	MaxBitrate int
+}
+
+// ParseQuality parses a quality: best, worst, a maximum height such as 720
+// or 720p, or a maximum bitrate such as 2500k or 3M.
+func ParseQuality(s string) (Quality, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "best":
+		return Quality{}, nil
+	case "worst":
+		return Quality{Worst: true}, nil
+	}
+	value, multiplier := s, 0.0
+	switch {
+	case strings.HasSuffix(s, "p"):
+		value = strings.TrimSuffix(s, "p")
+	case strings.HasSuffix(s, "k"):
+		value, multiplier = strings.TrimSuffix(s, "k"), 1e3
+	case strings.HasSuffix(s, "m"):
+		value, multiplier = strings.TrimSuffix(s, "m"), 1e6
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return Quality{}, fmt.Errorf("invalid quality %q, expected best, worst, a height (720) or a bitrate (2500k)", s)
+	}
+	if multiplier > 0 {
+		return Quality{MaxBitrate: int(n * multiplier)}, nil
+	}
+	return Quality{MaxHeight: int(n)}, nil
+}
+
+// codecFamilies maps the video codec names to the prefixes of their codecs
+// strings.
+var codecFamilies = map[string][]string{
+	"avc":  {"avc1", "avc3"},
+	"hevc": {"hvc1", "hev1"},
+	"av1":  {"av01"},
+	"vp9":  {"vp09"},
+}
+
+// ParseVideoCodec validates a video codec name, the aliases h264, h265 and
+// the codecs strings prefixes are accepted.
+func ParseVideoCodec(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "avc", "hevc", "av1", "vp9":
+		return s, nil
+	case "h264", "avc1", "avc3":
+		return "avc", nil
+	case "h265", "hvc1", "hev1":
+		return "hevc", nil
+	case "av01":
+		return "av1", nil
+	case "vp09":
+		return "vp9", nil
+	}
+	return "", fmt.Errorf("unsupported video codec %q, expected avc, hevc, av1 or vp9", s)
+}
+
+// HasCodec reports whether one of the codecs of the track belongs to the
+// codec family.
+func (t *Track) HasCodec(family string) bool {
+	for _, c := range strings.Split(strings.ToLower(t.Codecs), ",") {
+		for _, prefix := range codecFamilies[family] {
+			if strings.HasPrefix(strings.TrimSpace(c), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Preferences are the tracks to pick in a manifest.
+type Preferences struct {
+	Quality Quality
+	// AudioLangs are the wanted audio languages, in order of preference,
+	// "qad" being the audio description. The default audio track is picked
+	// when empty or when none of them is available.
+	AudioLangs []string
+	// MultiAudio keeps an audio track per wanted language, or per available
+	// language when AudioLangs is empty, instead of only the first match.
+	MultiAudio bool
+	// VideoCodec restricts the video to a codec family: avc, hevc, av1 or
+	// vp9.
+	VideoCodec string
+	// SubLangs are the wanted subtitles languages, all of them when empty.
+	SubLangs []string
+}
+
+// Selection is the tracks picked in a manifest. Audio is empty when the
+// audio is muxed in the video track without being listed, the first audio
+// track is the preferred one.
+type Selection struct {
+	Video *Track
+	Audio []*Track
+	Text  []*Track
+}
+
+// Tracks lists all the selected tracks.
+func (s *Selection) Tracks() []*Track {
+	var tracks []*Track
+	if s.Video != nil {
+		tracks = append(tracks, s.Video)
+	}
+	tracks = append(tracks, s.Audio...)
+	return append(tracks, s.Text...)
+}
+
+// Select picks the tracks to download according to the preferences. The
+// DRM protected tracks are left out.
+func (m *Manifest) Select(p Preferences) (*Selection, error) {
+	sel := &Selection{}
+	videos := m.TracksOf(Video)
+	if len(videos) > 0 {
+		video, err := selectVideo(videos, p)
+		if err != nil {
+			return nil, err
+		}
+		sel.Video = video
+	}
+
+	audios := unprotected(m.TracksOf(Audio))
+	if sel.Video != nil && m.Format == HLS {
+		// the HLS variants play the renditions of their audio group, or
+		// have the audio muxed in
+		audios = filterTracks(audios, func(t *Track) bool {
+			return sel.Video.AudioGroup != "" && t.Group == sel.Video.AudioGroup
+		})
+	} else {
+		// the muxed renditions come with a variant
+		audios = filterTracks(audios, func(t *Track) bool { return !t.Muxed })
+	}
+	sel.Audio = selectAudio(audios, p)
+	if sel.Video == nil && len(sel.Audio) == 0 {
+		return nil, fmt.Errorf("%w: no video nor audio track found", ErrNoTrack)
+	}
+
+	sel.Text = m.SelectText(p.SubLangs)
+	return sel, nil
+}
+
+// SelectText returns the subtitles in the wanted languages, all of them when
+// langs is empty.
+func (m *Manifest) SelectText(langs []string) []*Track {
+	return filterTracks(unprotected(m.TracksOf(Text)), func(t *Track) bool {
+		return MatchLang(t.Lang, langs)
+	})
+}
+
+func filterTracks(tracks []*Track, keep func(*Track) bool) []*Track {
+	var filtered []*Track
+	for _, t := range tracks {
+		if keep(t) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+func unprotected(tracks []*Track) []*Track {
+	return filterTracks(tracks, func(t *Track) bool { return !t.Protected })
+}
+
+// sortByQuality sorts the tracks from the best to the worst quality.
+func sortByQuality(tracks []*Track) {
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if tracks[i].Height != tracks[j].Height {
+			return tracks[i].Height > tracks[j].Height
+		}
+		return tracks[i].Bandwidth > tracks[j].Bandwidth
+	})
+}
+
+func selectVideo(videos []*Track, p Preferences) (*Track, error) {
+	candidates := unprotected(videos)
+	if len(candidates) == 0 {
+		return nil, ErrProtected
+	}
+	if p.VideoCodec != "" {
+		var codecs []string
+		for _, t := range candidates {
+			codecs = append(codecs, t.Codecs)
+		}
+		candidates = filterTracks(candidates, func(t *Track) bool { return t.HasCodec(p.VideoCodec) })
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("%w: no %s video, the available codecs are %s", ErrNoTrack, p.VideoCodec, strings.Join(codecs, ", "))
+		}
+	}
+	sortByQuality(candidates)
+
+	q := p.Quality
+	within := filterTracks(candidates, func(t *Track) bool {
+		return (q.MaxHeight == 0 || t.Height <= q.MaxHeight) && (q.MaxBitrate == 0 || t.Bandwidth <= q.MaxBitrate)
+	})
+	if len(within) == 0 {
+		// nothing under the limits, the closest is the smallest
+		return candidates[len(candidates)-1], nil
+	}
+	if q.Worst {
+		return within[len(within)-1], nil
+	}
+	return within[0], nil
+}
+
+// matchAudio reports whether the audio track is in the wanted language, the
+// audio description tracks only match "qad".
+func matchAudio(t *Track, lang string) bool {
+	if NormalizeLang(lang) == "qad" {
+		return t.AudioDescription
+	}
+	return !t.AudioDescription && MatchLang(t.Lang, []string{lang})
+}
+
+// selectAudio picks the audio track of the first available wanted language,
+// or the default one, followed by the other languages with MultiAudio.
+func selectAudio(audios []*Track, p Preferences) []*Track {
+	if len(audios) == 0 {
+		return nil
+	}
+	var matching []*Track
+	for _, lang := range p.AudioLangs {
+		if matching = filterTracks(audios, func(t *Track) bool { return matchAudio(t, lang) }); len(matching) > 0 {
+			break
+		}
+	}
+	if len(matching) == 0 {
+		matching = filterTracks(audios, func(t *Track) bool { return t.Default && !t.AudioDescription })
+	}
+	if len(matching) == 0 {
+		matching = filterTracks(audios, func(t *Track) bool { return !t.AudioDescription })
+	}
+	if len(matching) == 0 {
+		matching = audios
+	}
+	selected := []*Track{bestAudio(matching, p.Quality.Worst)}
+	if !p.MultiAudio {
+		return selected
+	}
+
+	picked := map[string]bool{audioVersion(selected[0]): true}
+	add := func(matching []*Track) {
+		if len(matching) == 0 || picked[audioVersion(matching[0])] {
+			return
+		}
+		picked[audioVersion(matching[0])] = true
+		selected = append(selected, bestAudio(matching, p.Quality.Worst))
+	}
+	for _, lang := range p.AudioLangs {
+		add(filterTracks(audios, func(t *Track) bool { return matchAudio(t, lang) }))
+	}
+	if len(p.AudioLangs) == 0 {
+		for _, a := range audios {
+			add(filterTracks(audios, func(t *Track) bool { return audioVersion(t) == audioVersion(a) }))
+		}
+	}
+	return selected
+}
+
+// audioVersion identifies the tracks of a same audio version, in different
+// bitrates or codecs.
+func audioVersion(t *Track) string {
+	return fmt.Sprintf("%s/%t", NormalizeLang(t.Lang), t.AudioDescription)
+}
+
+// bestAudio picks the bitrate of the version of the first track.
+func bestAudio(matching []*Track, worst bool) *Track {
+	version := audioVersion(matching[0])
+	matching = filterTracks(matching, func(t *Track) bool { return audioVersion(t) == version })
+	sortByQuality(matching)
+	if worst {
+		return matching[len(matching)-1]
+	}
+	return matching[0]
+}
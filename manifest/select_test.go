@@ -0,0 +1,189 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQuality(t *testing.T) {
+	tests := map[string]Quality{
+		"":      {},
+		"best":  {},
+		"worst": {Worst: true},
+		"720":   {MaxHeight: 720},
+		"1080p": {MaxHeight: 1080},
+		"2500k": {MaxBitrate: 2500000},
+		"1.5M":  {MaxBitrate: 1500000},
+	}
+	for in, expected := range tests {
+		got, err := ParseQuality(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("%q: got %+v, expected %+v", in, got, expected)
+		}
+	}
+	for _, in := range []string{"high", "-1", "k"} {
+		if _, err := ParseQuality(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestSelectDASH(t *testing.T) {
+	m := parseDASHFixture(t, "testdata/timeline.mpd", "https://cdn.example/manifest.mpd")
+	tests := []struct {
+		name  string
+		prefs Preferences
+		video string
+		audio string
+		texts int
+	}{
+		{"defaults", Preferences{}, "video=2000000", "audio_fra=96000", 2},
+		{"worst", Preferences{Quality: Quality{Worst: true}}, "video=400000", "audio_fra=96000", 2},
+		{"max height", Preferences{Quality: Quality{MaxHeight: 480}}, "video=400000", "audio_fra=96000", 2},
+		{"max bitrate", Preferences{Quality: Quality{MaxBitrate: 3000000}}, "video=2000000", "audio_fra=96000", 2},
+		{"under every track", Preferences{Quality: Quality{MaxHeight: 240}}, "video=400000", "audio_fra=96000", 2},
+		{"audio description", Preferences{AudioLangs: []string{"qad"}}, "video=2000000", "audio_qad=96000", 2},
+		{"missing language", Preferences{AudioLangs: []string{"eng"}}, "video=2000000", "audio_fra=96000", 2},
+		{"preference order", Preferences{AudioLangs: []string{"eng", "qad", "fra"}}, "video=2000000", "audio_qad=96000", 2},
+		{"subtitles", Preferences{SubLangs: []string{"eng"}}, "video=2000000", "audio_fra=96000", 0},
+		{"codec", Preferences{VideoCodec: "avc"}, "video=2000000", "audio_fra=96000", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := m.Select(tt.prefs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sel.Video.ID != tt.video {
+				t.Errorf("got video %s, expected %s", sel.Video.ID, tt.video)
+			}
+			if len(sel.Audio) != 1 || sel.Audio[0].ID != tt.audio {
+				t.Errorf("got audio %v, expected %s", sel.Audio, tt.audio)
+			}
+			if len(sel.Text) != tt.texts {
+				t.Errorf("got %d subtitles, expected %d", len(sel.Text), tt.texts)
+			}
+		})
+	}
+
+	multi := []struct {
+		langs    []string
+		expected []string
+	}{
+		{nil, []string{"audio_fra=96000", "audio_qad=96000"}},
+		{[]string{"qad", "eng", "fra"}, []string{"audio_qad=96000", "audio_fra=96000"}},
+		{[]string{"fra", "eng"}, []string{"audio_fra=96000"}},
+	}
+	for _, tt := range multi {
+		sel, err := m.Select(Preferences{AudioLangs: tt.langs, MultiAudio: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, a := range sel.Audio {
+			ids = append(ids, a.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.expected) {
+			t.Errorf("multi audio %v: got %v, expected %v", tt.langs, ids, tt.expected)
+		}
+	}
+
+	if _, err := m.Select(Preferences{VideoCodec: "hevc"}); !errors.Is(err, ErrNoTrack) {
+		t.Errorf("expected ErrNoTrack for a missing codec, got %v", err)
+	}
+
+	protected := parseDASHFixture(t, "testdata/template.mpd", "https://cdn.example/manifest.mpd")
+	if _, err := protected.Select(Preferences{}); !errors.Is(err, ErrProtected) {
+		t.Errorf("expected ErrProtected, got %v", err)
+	}
+}
+
+func TestSelectHLS(t *testing.T) {
+	f, err := os.Open("testdata/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := ParseHLS(f, "https://cdn.example/hls/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel, err := m.Select(Preferences{Quality: Quality{MaxHeight: 360}, AudioLangs: []string{"qad"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel.Video.PlaylistURL != "https://cdn.example/hls/video_400.m3u8" {
+		t.Errorf("unexpected variant %s", sel.Video.PlaylistURL)
+	}
+	// the audio only variant isn't part of the audio group
+	expected := []*Track{m.TracksOf(Audio)[1]}
+	if !reflect.DeepEqual(sel.Audio, expected) {
+		t.Errorf("got audio %v, expected %v", sel.Audio, expected)
+	}
+	if len(sel.Tracks()) != 4 {
+		t.Errorf("expected the video, audio and 2 subtitles, got %d tracks", len(sel.Tracks()))
+	}
+}
+
+func TestSelectHLSMuxedMultiAudio(t *testing.T) {
+	m, err := ParseHLS(strings.NewReader(muxedAudioMaster), "https://cdn.example/hls/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		langs    []string
+		expected []string
+	}{
+		{nil, []string{"audio/Français", "audio/English"}},
+		{[]string{"eng", "fra"}, []string{"audio/English", "audio/Français"}},
+		{[]string{"fra"}, []string{"audio/Français"}},
+	}
+	for _, tt := range tests {
+		sel, err := m.Select(Preferences{AudioLangs: tt.langs, MultiAudio: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, a := range sel.Audio {
+			ids = append(ids, a.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.expected) {
+			t.Errorf("multi audio %v: got %v, expected %v", tt.langs, ids, tt.expected)
+		}
+	}
+}
+
+func TestSelectText(t *testing.T) {
+	m := &Manifest{Tracks: []*Track{
+		{Kind: Audio, Lang: "fr"},
+		{Kind: Text, Lang: "fr"},
+		{Kind: Text, Lang: "fr", SDH: true},
+		{Kind: Text, Lang: "en"},
+		{Kind: Text, Lang: "de", Protected: true},
+	}}
+	tests := []struct {
+		name     string
+		langs    []string
+		expected []*Track
+	}{
+		{"all", nil, m.Tracks[1:4]},
+		{"language", []string{"eng"}, m.Tracks[3:4]},
+		// the standard and SDH subtitles of a language are both kept
+		{"sdh", []string{"fra"}, m.Tracks[1:3]},
+		{"several languages", []string{"en", "fr"}, m.Tracks[1:4]},
+		{"protected", []string{"deu"}, nil},
+		{"missing", []string{"ita"}, nil},
+	}
+	for _, tt := range tests {
+		if got := m.SelectText(tt.langs); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: got %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
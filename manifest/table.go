@@ -0,0 +1,71 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteTable writes the tracks as a table, one line per track with its
+// resolution, bitrate, codecs and language.
+func WriteTable(w io.Writer, tracks []*Track) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tID\tRESOLUTION\tFPS\tBITRATE\tCODECS\tLANG\tCHANNELS\tNOTES")
+	for _, t := range tracks {
+		resolution := "-"
+		if t.Width > 0 && t.Height > 0 {
+			resolution = fmt.Sprintf("%dx%d", t.Width, t.Height)
+		}
+		bitrate := "-"
+		switch {
+		case t.Bandwidth >= 1000:
+			bitrate = fmt.Sprintf("%dk", t.Bandwidth/1000)
+		case t.Bandwidth > 0:
+			bitrate = fmt.Sprint(t.Bandwidth)
+		}
+		codecs := t.Codecs
+		if codecs == "" {
+			codecs = t.MimeType
+		}
+		lang := ""
+		if t.Lang != "" || t.Kind != Video {
+			lang = NormalizeLang(t.Lang)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Kind, dash(t.ID), resolution, dash(t.FrameRate), bitrate, dash(codecs), dash(lang), dash(t.Channels), strings.Join(t.notes(), ", "))
+	}
+	return tw.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// notes lists the flags of the track worth knowing when picking one.
+func (t *Track) notes() []string {
+	var notes []string
+	if t.Label != "" {
+		notes = append(notes, fmt.Sprintf("%q", t.Label))
+	}
+	if t.Default {
+		notes = append(notes, "default")
+	}
+	if t.Kind == Text {
+		if t.SDH {
+			notes = append(notes, "SDH")
+		} else {
+			notes = append(notes, "standard")
+		}
+	}
+	if t.AudioDescription {
+		notes = append(notes, "audio description")
+	}
+	if t.Protected {
+		notes = append(notes, "DRM")
+	}
+	return notes
+}
@@ -0,0 +1,25 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTable(t *testing.T) {
+	m := parseDASHFixture(t, "testdata/timeline.mpd", "https://cdn.example/manifest.mpd")
+	var b strings.Builder
+	if err := WriteTable(&b, m.Tracks); err != nil {
+		t.Fatal(err)
+	}
+	expected := `KIND   ID                   RESOLUTION  FPS  BITRATE  CODECS       LANG  CHANNELS  NOTES
+video  video=400000         640x360     25   400k     avc1.4D401E  -     -         
+video  video=2000000        1280x720    25   2000k    avc1.64001F  -     -         
+audio  audio_fra=96000      -           -    96k      mp4a.40.2    fra   2         default
+audio  audio_qad=96000      -           -    96k      mp4a.40.2    qad   -         audio description
+text   textstream_fra=1000  -           -    1k       stpp         fra   -         standard
+text   sdh                  -           -    256      text/vtt     fra   -         SDH
+`
+	if got := b.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
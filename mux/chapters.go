@@ -0,0 +1,62 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Chapter is a titled section of a video.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// ffmetadataEscaper escapes the special characters of the ffmetadata format.
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, `;`, `\;`, `#`, `\#`, "\n", "\\\n")
+
+// WriteFFMetadata writes the chapters in the ffmpeg metadata format, the
+// one ffmpeg reads with -map_chapters.
+// See https://ffmpeg.org/ffmpeg-formats.html#Metadata-1
+func WriteFFMetadata(w io.Writer, chapters []Chapter) error {
+	if _, err := io.WriteString(w, ";FFMETADATA1\n"); err != nil {
+		return err
+	}
+	for _, c := range chapters {
+		_, err := fmt.Fprintf(w, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.Start.Milliseconds(), c.End.Milliseconds(), ffmetadataEscaper.Replace(c.Title))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteOGMChapters writes the chapters in the OGM format, the simple chapter
+// format read by mkvmerge and most players.
+func WriteOGMChapters(w io.Writer, chapters []Chapter) error {
+	for i, c := range chapters {
+		_, err := fmt.Fprintf(w, "CHAPTER%02d=%s\nCHAPTER%02dNAME=%s\n", i+1, ogmTimestamp(c.Start), i+1, c.Title)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ogmTimestamp formats a duration as HH:MM:SS.mmm
+func ogmTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// ChaptersEdit replaces the chapters of a video with the ones of an
+// ffmetadata file, keeping its tags.
+func ChaptersEdit(ffmetadataPath string) Edit {
+	return Edit{
+		Inputs: []string{ffmetadataPath},
+		Args:   []string{"-map", "0", "-map_metadata", "0", "-map_chapters", "1", "-c", "copy"},
+	}
+}
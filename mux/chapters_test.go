@@ -0,0 +1,57 @@
+package mux
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var testChapters = []Chapter{
+	{Title: "Episode", Start: 0, End: 12 * time.Second},
+	{Title: "Intro", Start: 12 * time.Second, End: 50 * time.Second},
+	{Title: "Episode", Start: 50 * time.Second, End: 25*time.Minute + 12*time.Second},
+	{Title: "Credits; end", Start: 25*time.Minute + 12*time.Second, End: 26*time.Minute + 8*time.Second + 500*time.Millisecond},
+}
+
+func TestWriteFFMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFFMetadata(&buf, testChapters[2:]); err != nil {
+		t.Fatal(err)
+	}
+	expected := `;FFMETADATA1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=50000
+END=1512000
+title=Episode
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=1512000
+END=1568500
+title=Credits\; end
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestWriteOGMChapters(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteOGMChapters(&buf, testChapters); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CHAPTER01=00:00:00.000
+CHAPTER01NAME=Episode
+CHAPTER02=00:00:12.000
+CHAPTER02NAME=Intro
+CHAPTER03=00:00:50.000
+CHAPTER03NAME=Episode
+CHAPTER04=00:25:12.000
+CHAPTER04NAME=Credits; end
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
@@ -0,0 +1,56 @@
+package mux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Containers lists the supported output containers, by file extension.
+var Containers = []string{"mkv", "mp4", "ts"}
+
+// ParseContainer validates a container name, "matroska", "m4v" and "mpegts"
+// are accepted as aliases.
+func ParseContainer(s string) (string, error) {
+	switch c := containerName(s); c {
+	case "mkv", "mp4", "ts":
+		return c, nil
+	case "matroska":
+		return "mkv", nil
+	case "m4v":
+		return "mp4", nil
+	case "mpegts":
+		return "ts", nil
+	}
+	return "", fmt.Errorf("unsupported container %q, expected one of %s", s, strings.Join(Containers, ", "))
+}
+
+func containerName(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// subtitleCodecs are the text formats the subtitles are converted to, per
+// container.
+var subtitleCodecs = map[string]string{
+	"mkv":  "srt",
+	"mp4":  "mov_text",
+	"m4v":  "mov_text",
+	"mov":  "mov_text",
+	"webm": "webvtt",
+}
+
+// SupportsSubtitles reports whether the container of the given extension can
+// hold text subtitles, MPEG-TS can't.
+func SupportsSubtitles(ext string) bool {
+	_, ok := subtitleCodecs[containerName(ext)]
+	return ok
+}
+
+// SupportsChapters reports whether the container of the given extension can
+// hold chapters.
+func SupportsChapters(ext string) bool {
+	switch containerName(ext) {
+	case "mkv", "mp4", "m4v", "mov", "webm":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package mux
+
+import "testing"
+
+func TestParseContainer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"mkv", "mkv"},
+		{".MP4", "mp4"},
+		{"matroska", "mkv"},
+		{"mpegts", "ts"},
+		{"avi", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseContainer(tt.input)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.input)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("%s: got %q (%v), expected %s", tt.input, got, err, tt.expected)
+		}
+	}
+	if SupportsSubtitles("ts") || !SupportsSubtitles(".mp4") {
+		t.Error("only ts can't hold subtitles")
+	}
+	if SupportsChapters(".ts") || !SupportsChapters("mkv") {
+		t.Error("only ts can't hold chapters")
+	}
+}
@@ -0,0 +1,149 @@
+package mux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range is a section of a video. An End of 0 means until the end of the
+// video.
+type Range struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// KeepRanges returns the sections of a video of the given length left once
+// the removed sections are cut out. The length can be 0 if unknown.
+func KeepRanges(length time.Duration, removed []Range) []Range {
+	removed = append([]Range(nil), removed...)
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Start < removed[j].Start })
+
+	var keep []Range
+	cursor := time.Duration(0)
+	for _, r := range removed {
+		if r.End == 0 {
+			r.End = length
+		}
+		if r.Start > cursor {
+			keep = append(keep, Range{Start: cursor, End: r.Start})
+		}
+		if r.End == 0 {
+			// removed until the end of a video of unknown length
+			return keep
+		}
+		if r.End > cursor {
+			cursor = r.End
+		}
+	}
+	if length == 0 || cursor < length {
+		keep = append(keep, Range{Start: cursor, End: length})
+	}
+	return keep
+}
+
+// RemapChapters moves the chapters to the timeline of the video once only
+// the kept sections are left. The chapters falling entirely in a removed
+// section are dropped.
+func RemapChapters(chapters []Chapter, keep []Range) []Chapter {
+	var remapped []Chapter
+	for _, c := range chapters {
+		var start, end time.Duration
+		found := false
+		offset := time.Duration(0)
+		for _, k := range keep {
+			kEnd := k.End
+			if kEnd == 0 {
+				kEnd = c.End
+			}
+			// intersection of the chapter and the kept section
+			s, e := maxDuration(c.Start, k.Start), minDuration(c.End, kEnd)
+			if s < e {
+				if !found {
+					start = s - k.Start + offset
+					found = true
+				}
+				end = e - k.Start + offset
+			}
+			offset += kEnd - k.Start
+		}
+		if found {
+			remapped = append(remapped, Chapter{Title: c.Title, Start: start, End: end})
+		}
+	}
+	return remapped
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// seconds formats a duration the way ffmpeg expects it.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// Cut only keeps the given sections of the video at path. The streams are
+// copied so the cuts happen on the nearest key frames.
+func Cut(path string, keep []Range) error {
+	if len(keep) == 0 {
+		return fmt.Errorf("nothing left to keep in %s", path)
+	}
+	trim := func(r Range) Edit {
+		e := Edit{Args: []string{"-ss", seconds(r.Start)}}
+		if r.End > 0 {
+			e.Args = append(e.Args, "-to", seconds(r.End))
+		}
+		// the chapters don't match the new timeline, see RemapChapters
+		e.Args = append(e.Args, "-map", "0", "-map_chapters", "-1", "-c", "copy", "-avoid_negative_ts", "make_zero")
+		return e
+	}
+	if len(keep) == 1 {
+		return trim(keep[0]).Apply(path)
+	}
+
+	// extract the sections then join them
+	ext := filepath.Ext(path)
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".cut")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var list strings.Builder
+	for i, r := range keep {
+		part := filepath.Join(dir, fmt.Sprintf("part%d%s", i, ext))
+		if err := trim(r).applyTo(path, part, false); err != nil {
+			return err
+		}
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(part, "'", `'\''`))
+	}
+	listPath := filepath.Join(dir, "parts.txt")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+
+	tmp := partPath(path)
+	args := []string{"-hide_banner", "-loglevel", "error", "-y",
+		"-f", "concat", "-safe", "0", "-i", listPath,
+		"-i", path, "-map", "0", "-map_metadata", "1", "-map_chapters", "-1", "-c", "copy", tmp}
+	if err := run(args...); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,42 @@
+package mux
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeepRanges(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	tests := []struct {
+		name     string
+		length   time.Duration
+		removed  []Range
+		expected []Range
+	}{
+		{"nothing removed", s(100), nil, []Range{{0, s(100)}}},
+		{"intro and credits", s(1568), []Range{{s(1512), s(1568)}, {s(12), s(50)}}, []Range{{0, s(12)}, {s(50), s(1512)}}},
+		{"intro at the start", s(100), []Range{{0, s(20)}}, []Range{{s(20), s(100)}}},
+		{"unknown length", 0, []Range{{s(10), s(20)}}, []Range{{0, s(10)}, {s(20), 0}}},
+		{"credits until the end", 0, []Range{{s(80), 0}}, []Range{{0, s(80)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeepRanges(tt.length, tt.removed); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRemapChapters(t *testing.T) {
+	s := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	keep := KeepRanges(s(1568), []Range{{s(12), s(50)}, {s(1512), s(1568)}})
+	expected := []Chapter{
+		{Title: "Episode", Start: 0, End: s(12)},
+		{Title: "Episode", Start: s(12), End: s(1474)},
+	}
+	if got := RemapChapters(testChapters, keep); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,95 @@
+// Package mux post-processes the downloaded videos with ffmpeg: tagging,
+// chapters, cutting and remuxing. The operations copy the streams, nothing is
+// re-encoded.
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	Debug  = false
+	Logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
+	// FfmpegPath is the ffmpeg binary to use, looked up in the PATH by
+	// default.
+	FfmpegPath = "ffmpeg"
+	// FfprobePath is the ffprobe binary used to verify the videos.
+	FfprobePath = "ffprobe"
+)
+
+// ErrNoFfmpeg is returned when ffmpeg isn't installed.
+var ErrNoFfmpeg = errors.New("ffmpeg wasn't found on your system, it is required to post-process the videos")
+
+// ErrNoFfprobe is returned when ffprobe, which comes with ffmpeg, isn't
+// installed.
+var ErrNoFfprobe = errors.New("ffprobe wasn't found on your system, it is required to verify the videos")
+
+// Edit describes an ffmpeg run rewriting a video: the video is the first
+// input, followed by Inputs, and Args are the output options.
+type Edit struct {
+	Inputs []string
+	Args   []string
+}
+
+// Apply runs the edit on the video at path. The result is written to a
+// temporary file next to the video which then replaces it, the video is left
+// untouched if ffmpeg fails.
+func (e Edit) Apply(path string) error {
+	return e.ApplyTo(path, path)
+}
+
+// ApplyTo runs the edit on the video at src and saves the result to dst,
+// which can have a different extension to change the container. src is
+// removed once the edit succeeded.
+func (e Edit) ApplyTo(src, dst string) error {
+	return e.applyTo(src, dst, src != dst)
+}
+
+func (e Edit) applyTo(src, dst string, removeSrc bool) error {
+	tmp := partPath(dst)
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", src}
+	for _, in := range e.Inputs {
+		args = append(args, "-i", in)
+	}
+	args = append(args, e.Args...)
+	args = append(args, tmp)
+	if err := run(args...); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	if removeSrc {
+		return os.Remove(src)
+	}
+	return nil
+}
+
+// partPath returns the temporary path used while writing path, keeping the
+// extension so ffmpeg picks the right container.
+func partPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".part" + ext
+}
+
+func run(args ...string) error {
+	ffmpeg, err := exec.LookPath(FfmpegPath)
+	if err != nil {
+		return ErrNoFfmpeg
+	}
+	if Debug {
+		Logger.Println(ffmpeg, strings.Join(args, " "))
+	}
+	out, err := exec.Command(ffmpeg, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed - %w\n%s", err, out)
+	}
+	return nil
+}
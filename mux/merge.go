@@ -0,0 +1,115 @@
+package mux
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Stream kinds, as ffmpeg stream specifiers.
+const (
+	Video     = "v"
+	Audio     = "a"
+	Subtitles = "s"
+)
+
+// Track is a file muxed by MergeEdit.
+type Track struct {
+	Path string
+	// Kind is Video, Audio or Subtitles.
+	Kind string
+	// Lang is the ISO 639-2 code of the language, if known.
+	Lang  string
+	Title string
+	// Default flags the track players pick by default.
+	Default bool
+	// HearingImpaired flags the SDH subtitles and VisualImpaired the audio
+	// description.
+	HearingImpaired bool
+	VisualImpaired  bool
+}
+
+// bibliographicLangs maps the ISO 639-2/T codes to the B codes Matroska
+// expects, ffmpeg converts them back for mp4.
+var bibliographicLangs = map[string]string{
+	"fra": "fre", "deu": "ger", "nld": "dut", "zho": "chi", "ces": "cze",
+	"ell": "gre", "fas": "per", "sqi": "alb", "hye": "arm", "eus": "baq",
+	"mya": "bur", "kat": "geo", "isl": "ice", "mkd": "mac", "mri": "mao",
+	"msa": "may", "ron": "rum", "slk": "slo", "bod": "tib", "cym": "wel",
+}
+
+// MergeEdit muxes the tracks into a container of the given extension. The
+// edit is applied to the first track, usually the video, and the other ones
+// are added as inputs, an audio track with the path of the first track is
+// the audio muxed in it. The audio and video are copied, the subtitles are
+// converted to a format the container supports, or left out when it can't
+// hold any. The languages, titles and
+// dispositions of the audio and subtitles tracks are set, only the tracks
+// flagged Default are played by default.
+func MergeEdit(ext string, tracks []Track) Edit {
+	if !SupportsSubtitles(ext) {
+		tracks = slices.DeleteFunc(slices.Clone(tracks), func(t Track) bool { return t.Kind == Subtitles })
+	}
+	e := Edit{}
+	// inputs are the ffmpeg inputs of the tracks
+	inputs := make([]int, len(tracks))
+	separateAudio := false
+	for i, t := range tracks {
+		if i == 0 {
+			continue
+		}
+		separateAudio = separateAudio || t.Kind == Audio
+		if t.Path != tracks[0].Path {
+			e.Inputs = append(e.Inputs, t.Path)
+			inputs[i] = len(e.Inputs)
+		}
+	}
+
+	for i, t := range tracks {
+		if i == 0 && !(t.Kind == Video && separateAudio) {
+			// the audio muxed in the video is kept when there's no other
+			e.Args = append(e.Args, "-map", "0")
+			continue
+		}
+		e.Args = append(e.Args, "-map", fmt.Sprintf("%d:%s", inputs[i], t.Kind))
+	}
+	e.Args = append(e.Args, "-c", "copy")
+	if codec, ok := subtitleCodecs[containerName(ext)]; ok {
+		e.Args = append(e.Args, "-c:s", codec)
+	}
+
+	counts := map[string]int{}
+	for _, t := range tracks {
+		spec := fmt.Sprintf("%s:%d", t.Kind, counts[t.Kind])
+		counts[t.Kind]++
+		if t.Kind == Video {
+			continue
+		}
+		if t.Lang != "" {
+			lang := t.Lang
+			if b, ok := bibliographicLangs[lang]; ok {
+				lang = b
+			}
+			e.Args = append(e.Args, "-metadata:s:"+spec, "language="+lang)
+		}
+		if t.Title != "" {
+			e.Args = append(e.Args, "-metadata:s:"+spec, "title="+t.Title)
+		}
+		var disposition []string
+		if t.Default {
+			disposition = append(disposition, "default")
+		}
+		if t.HearingImpaired {
+			disposition = append(disposition, "hearing_impaired")
+		}
+		if t.VisualImpaired {
+			disposition = append(disposition, "visual_impaired")
+		}
+		if len(disposition) == 0 {
+			// ffmpeg would flag the first track of each kind as default
+			disposition = []string{"0"}
+		}
+		e.Args = append(e.Args, "-disposition:"+spec, strings.Join(disposition, "+"))
+	}
+	return e
+}
@@ -0,0 +1,60 @@
+package mux
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeEdit(t *testing.T) {
+	video := Track{Path: "/tmp/video.mp4", Kind: Video}
+	fra := Track{Path: "/tmp/fra.m4a", Kind: Audio, Lang: "fra", Default: true}
+	qad := Track{Path: "/tmp/qad.m4a", Kind: Audio, Lang: "qad", Title: "Audio description", VisualImpaired: true}
+	subs := Track{Path: "/tmp/fra.srt", Kind: Subtitles, Lang: "fra", HearingImpaired: true}
+
+	tests := []struct {
+		name           string
+		ext            string
+		tracks         []Track
+		expectedInputs []string
+		expected       []string
+	}{
+		{"mkv", "mkv", []Track{video, fra, qad, subs},
+			[]string{"/tmp/fra.m4a", "/tmp/qad.m4a", "/tmp/fra.srt"},
+			[]string{"-map", "0:v", "-map", "1:a", "-map", "2:a", "-map", "3:s", "-c", "copy", "-c:s", "srt",
+				"-metadata:s:a:0", "language=fre", "-disposition:a:0", "default",
+				"-metadata:s:a:1", "language=qad", "-metadata:s:a:1", "title=Audio description", "-disposition:a:1", "visual_impaired",
+				"-metadata:s:s:0", "language=fre", "-disposition:s:0", "hearing_impaired"}},
+		{"muxed audio", ".mp4", []Track{video, subs},
+			[]string{"/tmp/fra.srt"},
+			[]string{"-map", "0", "-map", "1:s", "-c", "copy", "-c:s", "mov_text",
+				"-metadata:s:s:0", "language=fre", "-disposition:s:0", "hearing_impaired"}},
+		{"audio muxed in the video", "mkv", []Track{video, {Path: video.Path, Kind: Audio, Lang: "fra", Default: true}, qad},
+			[]string{"/tmp/qad.m4a"},
+			[]string{"-map", "0:v", "-map", "0:a", "-map", "1:a", "-c", "copy", "-c:s", "srt",
+				"-metadata:s:a:0", "language=fre", "-disposition:a:0", "default",
+				"-metadata:s:a:1", "language=qad", "-metadata:s:a:1", "title=Audio description", "-disposition:a:1", "visual_impaired"}},
+		{"audio muxed in the video after another one", "mkv", []Track{video, fra, {Path: video.Path, Kind: Audio, Lang: "eng"}},
+			[]string{"/tmp/fra.m4a"},
+			[]string{"-map", "0:v", "-map", "1:a", "-map", "0:a", "-c", "copy", "-c:s", "srt",
+				"-metadata:s:a:0", "language=fre", "-disposition:a:0", "default",
+				"-metadata:s:a:1", "language=eng", "-disposition:a:1", "0"}},
+		{"no subtitles in ts", "ts", []Track{video, fra, subs},
+			[]string{"/tmp/fra.m4a"},
+			[]string{"-map", "0:v", "-map", "1:a", "-c", "copy",
+				"-metadata:s:a:0", "language=fre", "-disposition:a:0", "default"}},
+		{"audio only", "ts", []Track{fra, {Path: "/tmp/eng.m4a", Kind: Audio, Lang: "eng"}},
+			[]string{"/tmp/eng.m4a"},
+			[]string{"-map", "0", "-map", "1:a", "-c", "copy",
+				"-metadata:s:a:0", "language=fre", "-disposition:a:0", "default",
+				"-metadata:s:a:1", "language=eng", "-disposition:a:1", "0"}},
+	}
+	for _, tt := range tests {
+		e := MergeEdit(tt.ext, tt.tracks)
+		if !reflect.DeepEqual(e.Inputs, tt.expectedInputs) {
+			t.Errorf("%s: got inputs %v, expected %v", tt.name, e.Inputs, tt.expectedInputs)
+		}
+		if !reflect.DeepEqual(e.Args, tt.expected) {
+			t.Errorf("%s: got\n%v\nexpected\n%v", tt.name, e.Args, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mux
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata is the information written to the container tags.
+type Metadata struct {
+	Title       string
+	Show        string
+	Season      int
+	Episode     int
+	AirDate     time.Time
+	Channel     string
+	Description string
+	Genre       string
+}
+
+// Tags returns the ffmpeg metadata keys of the non empty values, in a stable
+// order. The keys are the ones the mp4 muxer maps to the iTunes TV atoms,
+// the Matroska muxer stores them as is.
+func (m Metadata) Tags() [][2]string {
+	var tags [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			tags = append(tags, [2]string{key, value})
+		}
+	}
+	add("title", m.Title)
+	add("show", m.Show)
+	if m.Season > 0 {
+		add("season_number", strconv.Itoa(m.Season))
+	}
+	if m.Episode > 0 {
+		add("episode_sort", strconv.Itoa(m.Episode))
+	}
+	if m.Season > 0 && m.Episode > 0 {
+		add("episode_id", fmt.Sprintf("S%02dE%02d", m.Season, m.Episode))
+	}
+	if !m.AirDate.IsZero() {
+		add("date", m.AirDate.Format("2006-01-02"))
+	}
+	add("network", m.Channel)
+	add("genre", m.Genre)
+	add("description", m.Description)
+	add("synopsis", m.Description)
+	return tags
+}
+
+// TagEdit writes the metadata and the cover art, if any, to a video of the
+// given extension. The cover is added as an attachment to Matroska files and
+// as an attached picture to mp4 files, other containers only get the tags.
+func TagEdit(ext string, m Metadata, coverPath string) Edit {
+	e := Edit{Args: []string{"-map", "0", "-c", "copy"}}
+	for _, tag := range m.Tags() {
+		e.Args = append(e.Args, "-metadata", tag[0]+"="+tag[1])
+	}
+	if coverPath == "" {
+		return e
+	}
+
+	switch strings.TrimPrefix(ext, ".") {
+	case "mkv":
+		mimetype := "image/jpeg"
+		if strings.EqualFold(filepath.Ext(coverPath), ".png") {
+			mimetype = "image/png"
+		}
+		e.Args = append(e.Args,
+			"-attach", coverPath,
+			"-metadata:s:t", "mimetype="+mimetype,
+			"-metadata:s:t", "filename=cover"+strings.ToLower(filepath.Ext(coverPath)))
+	case "mp4", "m4v":
+		e.Inputs = append(e.Inputs, coverPath)
+		e.Args = append(e.Args, "-map", "1", "-disposition:v:1", "attached_pic")
+	}
+	return e
+}
@@ -0,0 +1,49 @@
+package mux
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagEdit(t *testing.T) {
+	m := Metadata{
+		Title:   "Les volcans",
+		Show:    "C'est toujours pas sorcier",
+		Season:  1,
+		Episode: 12,
+		AirDate: time.Date(2023, 9, 30, 9, 15, 0, 0, time.UTC),
+		Channel: "France 4",
+	}
+	tags := []string{
+		"-map", "0", "-c", "copy",
+		"-metadata", "title=Les volcans",
+		"-metadata", "show=C'est toujours pas sorcier",
+		"-metadata", "season_number=1",
+		"-metadata", "episode_sort=12",
+		"-metadata", "episode_id=S01E12",
+		"-metadata", "date=2023-09-30",
+		"-metadata", "network=France 4",
+	}
+
+	tests := []struct {
+		name     string
+		ext      string
+		cover    string
+		expected Edit
+	}{
+		{"no cover", "mkv", "", Edit{Args: tags}},
+		{"mkv", "mkv", "/tmp/cover.jpg", Edit{Args: append(tags[:len(tags):len(tags)],
+			"-attach", "/tmp/cover.jpg", "-metadata:s:t", "mimetype=image/jpeg", "-metadata:s:t", "filename=cover.jpg")}},
+		{"mp4", ".mp4", "/tmp/cover.jpg", Edit{Inputs: []string{"/tmp/cover.jpg"}, Args: append(tags[:len(tags):len(tags)],
+			"-map", "1", "-disposition:v:1", "attached_pic")}},
+		{"ts", "ts", "/tmp/cover.jpg", Edit{Args: tags}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TagEdit(tt.ext, m, tt.cover); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
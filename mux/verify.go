@@ -0,0 +1,182 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrVerification is returned for the videos failing the verification.
+var ErrVerification = errors.New("verification failed")
+
+// Probe is what ffprobe found in a video.
+type Probe struct {
+	Duration time.Duration
+	Streams  []ProbeStream
+}
+
+// ProbeStream is a track of a probed video.
+type ProbeStream struct {
+	// Kind is Video, Audio or Subtitles, or the ffprobe codec type of the
+	// other streams.
+	Kind  string
+	Codec string
+	Lang  string
+}
+
+// Count returns the number of streams of the given kind.
+func (p *Probe) Count(kind string) int {
+	n := 0
+	for _, s := range p.Streams {
+		if s.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// ProbeFile runs ffprobe on the video at path.
+func ProbeFile(path string) (*Probe, error) {
+	ffprobe, err := exec.LookPath(FfprobePath)
+	if err != nil {
+		return nil, ErrNoFfprobe
+	}
+	args := []string{"-v", "error", "-show_format", "-show_streams", "-of", "json", path}
+	if Debug {
+		Logger.Println(ffprobe, strings.Join(args, " "))
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(ffprobe, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed - %w\n%s", err, stderr.Bytes())
+	}
+	return parseProbe(out)
+}
+
+// parseProbe decodes the JSON output of ffprobe.
+func parseProbe(data []byte) (*Probe, error) {
+	var out struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Tags      struct {
+				Language string `json:"language"`
+			} `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output - %w", err)
+	}
+	p := &Probe{}
+	if secs, err := strconv.ParseFloat(out.Format.Duration, 64); err == nil {
+		p.Duration = time.Duration(secs * float64(time.Second))
+	}
+	kinds := map[string]string{"video": Video, "audio": Audio, "subtitle": Subtitles}
+	for _, s := range out.Streams {
+		kind, ok := kinds[s.CodecType]
+		if !ok {
+			kind = s.CodecType
+		}
+		p.Streams = append(p.Streams, ProbeStream{Kind: kind, Codec: s.CodecName, Lang: s.Tags.Language})
+	}
+	return p, nil
+}
+
+// Expected is what a downloaded video should hold, the zero values aren't
+// checked.
+type Expected struct {
+	// Duration is the length of the video, it can be off by Tolerance,
+	// which defaults to 2% of the duration and at least 5s.
+	Duration  time.Duration
+	Tolerance time.Duration
+	// Video, Audio and Subtitles are the minimum number of tracks.
+	Video     int
+	Audio     int
+	Subtitles int
+}
+
+// Check compares the probed video to the expected one.
+func (p *Probe) Check(e Expected) error {
+	var problems []string
+	if e.Duration > 0 {
+		tolerance := e.Tolerance
+		if tolerance == 0 {
+			tolerance = max(e.Duration/50, 5*time.Second)
+		}
+		if diff := p.Duration - e.Duration; diff > tolerance || -diff > tolerance {
+			problems = append(problems, fmt.Sprintf("lasts %s instead of %s", p.Duration.Round(time.Second), e.Duration.Round(time.Second)))
+		}
+	}
+	for _, c := range []struct {
+		kind, name string
+		expected   int
+	}{{Video, "video", e.Video}, {Audio, "audio", e.Audio}, {Subtitles, "subtitles", e.Subtitles}} {
+		if n := p.Count(c.kind); n < c.expected {
+			problems = append(problems, fmt.Sprintf("has %d %s track(s) instead of %d", n, c.name, c.expected))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: the video %s", ErrVerification, strings.Join(problems, " and "))
+	}
+	return nil
+}
+
+// Verify checks that the video at path parses cleanly, reading all its
+// packets, and that it holds the expected tracks and duration. The failures
+// are reported as ErrVerification, ErrNoFfprobe and ErrNoFfmpeg when the
+// tools are missing.
+func Verify(path string, e Expected) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%w: %s is empty", ErrVerification, path)
+	}
+	p, err := ProbeFile(path)
+	if err != nil {
+		if errors.Is(err, ErrNoFfprobe) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	if err := readPackets(path); err != nil {
+		if errors.Is(err, ErrNoFfmpeg) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	return p.Check(e)
+}
+
+// readPackets demuxes the whole video, ffmpeg reports the truncated or
+// corrupted files.
+func readPackets(path string) error {
+	ffmpeg, err := exec.LookPath(FfmpegPath)
+	if err != nil {
+		return ErrNoFfmpeg
+	}
+	args := []string{"-hide_banner", "-v", "error", "-i", path, "-map", "0", "-c", "copy", "-f", "null", "-"}
+	if Debug {
+		Logger.Println(ffmpeg, strings.Join(args, " "))
+	}
+	out, err := exec.Command(ffmpeg, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed to read the video - %w\n%s", err, out)
+	}
+	if out = bytes.TrimSpace(out); len(out) > 0 {
+		return fmt.Errorf("ffmpeg reported errors reading the video:\n%s", out)
+	}
+	return nil
+}
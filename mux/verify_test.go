@@ -0,0 +1,66 @@
+package mux
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const ffprobeOutput = `{
+  "streams": [
+    {"index": 0, "codec_name": "h264", "codec_type": "video"},
+    {"index": 1, "codec_name": "aac", "codec_type": "audio", "tags": {"language": "fre"}},
+    {"index": 2, "codec_name": "aac", "codec_type": "audio", "tags": {"language": "qad"}},
+    {"index": 3, "codec_name": "subrip", "codec_type": "subtitle", "tags": {"language": "fre"}},
+    {"index": 4, "codec_name": "mjpeg", "codec_type": "attachment"}
+  ],
+  "format": {"filename": "video.mkv", "duration": "3130.040000"}
+}`
+
+func TestProbeCheck(t *testing.T) {
+	p, err := parseProbe([]byte(ffprobeOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Duration != 3130040*time.Millisecond {
+		t.Errorf("got duration %s", p.Duration)
+	}
+	if p.Count(Video) != 1 || p.Count(Audio) != 2 || p.Count(Subtitles) != 1 || p.Streams[1].Lang != "fre" {
+		t.Errorf("unexpected streams %+v", p.Streams)
+	}
+
+	tests := []struct {
+		name     string
+		expected Expected
+		ok       bool
+	}{
+		{"nothing expected", Expected{}, true},
+		{"same duration", Expected{Duration: 3130 * time.Second, Video: 1, Audio: 2, Subtitles: 1}, true},
+		{"within the tolerance", Expected{Duration: 52 * time.Minute}, true},
+		{"too short", Expected{Duration: 60 * time.Minute}, false},
+		{"custom tolerance", Expected{Duration: 3120 * time.Second, Tolerance: time.Second}, false},
+		{"missing audio", Expected{Audio: 3}, false},
+		{"missing subtitles", Expected{Subtitles: 2}, false},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.expected)
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrVerification) {
+			t.Errorf("%s: expected ErrVerification, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestVerifyEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.mkv")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(path, Expected{}); !errors.Is(err, ErrVerification) {
+		t.Errorf("expected ErrVerification, got %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/manifest"
+	"github.com/mattetti/francetv/mux"
+)
+
+// completedDownload is a video saved to disk, along with the metadata used to
+// post-process it.
+type completedDownload struct {
+	data   ftv.VideoData
+	stream *ftv.StreamData
+	// file is the video being post-processed, renamed to the path of the
+	// entry once verified.
+	file  string
+	entry ftv.ArchiveEntry
+	// selection are the tracks downloaded from the manifest.
+	selection *manifest.Selection
+	// kept are the sections left in the video once the intro or the
+	// credits were cut, nil if the video wasn't cut.
+	kept []mux.Range
+	// expected is what the video should hold once post-processed.
+	expected mux.Expected
+}
+
+// finishDownload runs the post-processing steps requested by the flags,
+// verifies the video, gives it its final name and records it in the archive.
+// The files saved next to the video are only written once it's verified.
+// Only the verification failures are returned, the video is left at d.file.
+func finishDownload(d completedDownload) error {
+	embedChapters := *chaptersFlag == "embed"
+	if *stripIntroFlag || *stripCreditsFlag {
+		if err := stripSections(&d); err != nil {
+			fmt.Println("Failed to cut", d.entry.Path)
+			fmt.Println(err)
+		}
+	}
+	if *embedMetadataFlag || *embedCoverFlag {
+		if err := embedMetadata(d); err != nil {
+			fmt.Println("Failed to embed the metadata in", d.entry.Path)
+			fmt.Println(err)
+		}
+	}
+	if embedChapters {
+		if err := writeChapters(d); err != nil {
+			fmt.Println("Failed to add the chapters to", d.entry.Path)
+			fmt.Println(err)
+		}
+	}
+	if err := verifyDownload(&d); err != nil {
+		return err
+	}
+	if err := os.Rename(d.file, d.entry.Path); err != nil {
+		return err
+	}
+
+	writeSidecars(d)
+	if *writeSubsFlag {
+		if err := writeSubtitles(d.selection.Text, d.entry.Path, d.kept); err != nil {
+			fmt.Println("Failed to save the subtitles of", d.entry.Path)
+			fmt.Println(err)
+		}
+	}
+	if *chaptersFlag != "" && !embedChapters {
+		if err := writeChapters(d); err != nil {
+			fmt.Println("Failed to save the chapters of", d.entry.Path)
+			fmt.Println(err)
+		}
+	}
+	if *thumbnailsFlag {
+		if err := writeThumbnails(d); err != nil {
+			fmt.Println("Failed to save the thumbnails of", d.entry.Path)
+			fmt.Println(err)
+		}
+	}
+	addToArchive(d.entry)
+	return nil
+}
+
+// sidecarPath returns the path of a file saved next to the video, sharing its
+// name so media servers pick it up: "Show - Title.nfo"
+func sidecarPath(videoPath, ext string) string {
+	return strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ext
+}
+
+// writeSidecars writes the metadata files requested by the flags. Failing to
+// write them isn't fatal, the video is still there.
+func writeSidecars(d completedDownload) {
+	if *nfoFlag {
+		path := sidecarPath(d.entry.Path, ".nfo")
+		if err := ftv.WriteNFO(path, d.data, d.stream); err != nil {
+			fmt.Println("Failed to write", path)
+			fmt.Println(err)
+		}
+	}
+	if *infoJSONFlag {
+		path := sidecarPath(d.entry.Path, ".info.json")
+		if err := ftv.WriteInfoJSON(path, d.data, d.stream); err != nil {
+			fmt.Println("Failed to write", path)
+			fmt.Println(err)
+		}
+	}
+}
+
+// videoMetadata collects the tags written to the container.
+func videoMetadata(d completedDownload) mux.Metadata {
+	fields := ftv.NewNameFields(d.data, d.stream, "")
+	return mux.Metadata{
+		Title:       fields.DisplayTitle(),
+		Show:        fields.Program,
+		Season:      fields.Season,
+		Episode:     fields.Episode,
+		AirDate:     fields.Date,
+		Channel:     fields.Channel,
+		Description: d.data.Description,
+		Genre:       d.stream.Genre(),
+	}
+}
+
+// embedMetadata writes the tags and the cover art into the video file.
+func embedMetadata(d completedDownload) error {
+	m := videoMetadata(d)
+	if !*embedMetadataFlag {
+		m = mux.Metadata{}
+	}
+
+	var coverPath string
+	if *embedCoverFlag && d.stream.Meta.ImageURL != "" {
+		var err error
+		if coverPath, err = downloadCover(d.stream.Meta.ImageURL); err != nil {
+			fmt.Println("Failed to download the cover art, skipping it")
+			fmt.Println(err)
+		} else {
+			defer os.Remove(coverPath)
+		}
+	}
+	return mux.TagEdit(filepath.Ext(d.file), m, coverPath).Apply(d.file)
+}
+
+// imageExt returns the extension of the image at imageURL, .jpg if unknown.
+func imageExt(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil && path.Ext(u.Path) != "" {
+		return strings.ToLower(path.Ext(u.Path))
+	}
+	return ".jpg"
+}
+
+// downloadCover saves the image to a temporary file and returns its path.
+func downloadCover(imageURL string) (string, error) {
+	tmp, err := os.CreateTemp(tmpRoot, "cover-*"+imageExt(imageURL))
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+
+	f, err := downloadFile(imageURL, tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), f.Close()
+}
+
+// stripSections cuts the intro and/or the credits out of the video. The
+// sections france.tv didn't flag are left in place with a warning.
+func stripSections(d *completedDownload) error {
+	var removed []mux.Range
+	if *stripIntroFlag {
+		if start, end, ok := d.stream.Intro(); ok {
+			removed = append(removed, mux.Range{Start: start, End: end})
+		} else {
+			fmt.Printf("Warning: no intro timecode for %s, the intro is kept\n", d.entry.Path)
+		}
+	}
+	if *stripCreditsFlag {
+		if start, end, ok := d.stream.Credits(); ok {
+			removed = append(removed, mux.Range{Start: start, End: end})
+		} else {
+			fmt.Printf("Warning: no closing credits timecode for %s, the credits are kept\n", d.entry.Path)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	keep := mux.KeepRanges(d.stream.Length(), removed)
+	if err := mux.Cut(d.file, keep); err != nil {
+		return err
+	}
+	d.kept = keep
+	var length time.Duration
+	for _, r := range keep {
+		end := r.End
+		if end == 0 {
+			end = d.stream.Length()
+		}
+		length += end - r.Start
+	}
+	// the cuts happen on the key frames
+	d.expected.Duration = length
+	d.expected.Tolerance = max(length/50, 5*time.Second) + time.Duration(len(keep))*10*time.Second
+	return nil
+}
+
+// writeChapters embeds the chapters in the video or writes them to a sidecar
+// file, depending on the -chapters flag.
+func writeChapters(d completedDownload) error {
+	var chapters []mux.Chapter
+	for _, c := range d.stream.Chapters() {
+		chapters = append(chapters, mux.Chapter(c))
+	}
+	if d.kept != nil {
+		chapters = mux.RemapChapters(chapters, d.kept)
+	}
+	if len(chapters) == 0 {
+		fmt.Println("No chapter information available for", d.entry.Path)
+		return nil
+	}
+
+	switch *chaptersFlag {
+	case "ogm":
+		return writeFile(sidecarPath(d.entry.Path, ".chapters.txt"), func(f *os.File) error {
+			return mux.WriteOGMChapters(f, chapters)
+		})
+	case "ffmetadata":
+		return writeFile(sidecarPath(d.entry.Path, ".ffmetadata"), func(f *os.File) error {
+			return mux.WriteFFMetadata(f, chapters)
+		})
+	}
+
+	if ext := filepath.Ext(d.file); !mux.SupportsChapters(ext) {
+		return fmt.Errorf("the %s files can't hold chapters, use -chapters ffmetadata or ogm", strings.TrimPrefix(ext, "."))
+	}
+	tmp, err := os.CreateTemp(tmpRoot, "chapters-*.ffmetadata")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = mux.WriteFFMetadata(tmp, chapters)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return mux.ChaptersEdit(tmp.Name()).Apply(d.file)
+}
+
+// writeFile creates the file at path and fills it with write.
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeThumbnails downloads the spritesheets of the video, saves each
+// thumbnail as its own image and writes the WebVTT thumbnails track pointing
+// to the spritesheets.
+func writeThumbnails(d completedDownload) error {
+	sheet, ok := d.stream.Spritesheet()
+	if !ok {
+		fmt.Println("No thumbnails available for", d.entry.Path)
+		return nil
+	}
+	if d.kept != nil {
+		fmt.Println("Warning: the thumbnails of", d.entry.Path, "follow the timeline of the uncut video")
+	}
+	dir := sidecarPath(d.entry.Path, ".thumbnails")
+	framesDir := filepath.Join(dir, "frames")
+	if err := os.MkdirAll(framesDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	thumbs := sheet.Thumbnails(d.stream.Length())
+	var names []string
+	var frames []image.Image
+	for i, imageURL := range sheet.Images {
+		name := fmt.Sprintf("sprite_%d%s", i, imageExt(imageURL))
+		names = append(names, name)
+
+		f, err := downloadFile(imageURL, filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode the spritesheet %s - %w", imageURL, err)
+		}
+
+		var imageThumbs []ftv.Thumbnail
+		for _, t := range thumbs {
+			if t.Image == i {
+				imageThumbs = append(imageThumbs, t)
+			}
+		}
+		for _, frame := range ftv.SliceThumbnails(img, imageThumbs) {
+			framePath := filepath.Join(framesDir, fmt.Sprintf("%04d.jpg", len(frames)+1))
+			if err := writeFile(framePath, func(f *os.File) error {
+				return jpeg.Encode(f, frame, &jpeg.Options{Quality: 90})
+			}); err != nil {
+				return err
+			}
+			frames = append(frames, frame)
+		}
+	}
+
+	if err := writeFile(filepath.Join(dir, "thumbnails.vtt"), func(f *os.File) error {
+		return ftv.WriteThumbnailsVTT(f, thumbs, names)
+	}); err != nil {
+		return err
+	}
+	if *contactSheetFlag {
+		return writeFile(filepath.Join(dir, "contact-sheet.png"), func(f *os.File) error {
+			return png.Encode(f, ftv.ContactSheet(frames, sheet.Columns))
+		})
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/manifest"
+	"github.com/mattetti/francetv/mux"
+)
+
+func TestFinishDownloadVerificationFailure(t *testing.T) {
+	defer func(nfo, infoJSON bool) { *nfoFlag, *infoJSONFlag = nfo, infoJSON }(*nfoFlag, *infoJSONFlag)
+	*nfoFlag, *infoJSONFlag = true, true
+
+	dir := t.TempDir()
+	finalFile := filepath.Join(dir, "Show - S1E2.mkv")
+	file := processingPath(finalFile)
+	// a truncated mux
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := finishDownload(completedDownload{
+		stream:    &ftv.StreamData{},
+		selection: &manifest.Selection{},
+		file:      file,
+		entry:     ftv.ArchiveEntry{Path: finalFile},
+	})
+	if !errors.Is(err, mux.ErrVerification) {
+		t.Fatalf("expected a verification error, got %v", err)
+	}
+	// neither the video nor its sidecars get their final name
+	if names := dirNames(t, dir); len(names) != 1 || names[0] != filepath.Base(file) {
+		t.Fatalf("expected only the video being processed, got %v", names)
+	}
+
+	if err := quarantine(file, finalFile); err != nil {
+		t.Fatal(err)
+	}
+	if names := dirNames(t, dir); len(names) != 1 || names[0] != ".quarantine" {
+		t.Errorf("expected only the quarantine directory, got %v", names)
+	}
+	if names := dirNames(t, filepath.Join(dir, ".quarantine")); len(names) != 1 || filepath.Ext(names[0]) != ".mkv" {
+		t.Errorf("expected the quarantined video, got %v", names)
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
@@ -0,0 +1,154 @@
+// Package retry retries the HTTP requests failing with a network error, a
+// 5xx or a 429 status code, with an exponential backoff and jitter. The
+// Retry-After header sent by the servers is honored.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+var Logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
+
+// Policy tells how many times and how long to wait before retrying.
+type Policy struct {
+	// MaxRetries is the number of retries after the first attempt, 0
+	// disables the retries.
+	MaxRetries int
+	// MinDelay is the delay before the first retry, doubled on each retry
+	// up to MaxDelay. Half of the delay is random so the clients don't retry
+	// all at once.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultPolicy retries 3 times, waiting about 1s, 2s then 4s.
+var DefaultPolicy = Policy{MaxRetries: 3, MinDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// Retryable reports whether a request that got this response or error is
+// worth retrying.
+func Retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return res.StatusCode == http.StatusTooManyRequests ||
+		(res.StatusCode >= 500 && res.StatusCode != http.StatusNotImplemented)
+}
+
+// Delay returns how long to wait before the given retry, starting at 1. The
+// Retry-After header of the response, if any, is used instead of the backoff.
+// ok is false when the server asks to wait longer than MaxDelay.
+func (p Policy) Delay(retry int, res *http.Response) (d time.Duration, ok bool) {
+	if res != nil {
+		if after, found := retryAfter(res.Header.Get("Retry-After"), time.Now()); found {
+			return after, after <= p.MaxDelay
+		}
+	}
+	d = p.MinDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d, true
+}
+
+// retryAfter parses a Retry-After header, either a number of seconds or an
+// HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Transport is an http.RoundTripper retrying the idempotent requests
+// according to its policy.
+type Transport struct {
+	// Base makes the requests, http.DefaultTransport is used if nil.
+	Base   http.RoundTripper
+	Policy Policy
+
+	// sleep waits between the attempts, replaced in the tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a copy of client, http.DefaultClient if nil, retrying
+// its requests according to the policy.
+func NewClient(client *http.Client, p Policy) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &Transport{Base: client.Transport, Policy: p}
+	return &c
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return base.RoundTrip(req)
+	}
+	sleep := t.sleep
+	if sleep == nil {
+		sleep = wait
+	}
+
+	for retry := 1; ; retry++ {
+		res, err := base.RoundTrip(req)
+		if retry > t.Policy.MaxRetries || !Retryable(res, err) {
+			return res, err
+		}
+		delay, ok := t.Policy.Delay(retry, res)
+		if !ok {
+			return res, err
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			// the connection can be reused once the body is read
+			io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+		}
+		Logger.Printf("%s %s failed (%s), retry %d/%d in %s", req.Method, req.URL.Host+req.URL.Path, reason, retry, t.Policy.MaxRetries, delay.Round(time.Millisecond))
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
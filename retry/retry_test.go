@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client retrying with the policy, recording the
+// delays instead of sleeping.
+func newTestClient(p Policy, delays *[]time.Duration) *http.Client {
+	c := NewClient(nil, p)
+	c.Transport.(*Transport).sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return c
+}
+
+func TestTransport(t *testing.T) {
+	p := Policy{MaxRetries: 3, MinDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		name     string
+		statuses []int
+		header   string
+		expected int
+		attempts int
+		delays   []time.Duration
+	}{
+		{"success", []int{200}, "", 200, 1, nil},
+		{"transient", []int{503, 502, 200}, "", 200, 3, nil},
+		{"too many requests", []int{429, 200}, "2", 200, 2, nil},
+		{"not found", []int{404, 200}, "", 404, 1, nil},
+		{"not implemented", []int{501, 200}, "", 501, 1, nil},
+		{"gives up", []int{500, 500, 500, 500, 200}, "", 500, 4, nil},
+		{"retry after too long", []int{503, 200}, "60", 503, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempts]
+				attempts++
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(status)
+				io.WriteString(w, "body")
+			}))
+			defer srv.Close()
+
+			var delays []time.Duration
+			res, err := newTestClient(p, &delays).Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.expected {
+				t.Errorf("got status %d, expected %d", res.StatusCode, tt.expected)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("got %d attempts, expected %d", attempts, tt.attempts)
+			}
+			if len(delays) != tt.attempts-1 {
+				t.Errorf("got %d delays, expected %d", len(delays), tt.attempts-1)
+			}
+			if tt.header == "2" && !reflect.DeepEqual(delays, []time.Duration{2 * time.Second}) {
+				t.Errorf("expected Retry-After to be honored, got %v", delays)
+			}
+		})
+	}
+}
+
+func TestTransportNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	var delays []time.Duration
+	_, err := newTestClient(Policy{MaxRetries: 2, MinDelay: time.Millisecond, MaxDelay: time.Second}, &delays).Get(srv.URL)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(delays) != 2 {
+		t.Errorf("expected 2 retries, got %d", len(delays))
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := Policy{MinDelay: time.Second, MaxDelay: 5 * time.Second}
+	for retry, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			d, ok := p.Delay(retry, nil)
+			if !ok || d < max/2 || d > max {
+				t.Fatalf("retry %d: got %s, expected between %s and %s", retry, d, max/2, max)
+			}
+		}
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if d, ok := retryAfter(now.Add(3*time.Second).Format(http.TimeFormat), now); !ok || d != 3*time.Second {
+		t.Errorf("got %s, expected 3s from an HTTP date", d)
+	}
+	if _, ok := retryAfter("soon", now); ok {
+		t.Error("expected an invalid Retry-After to be ignored")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mattetti/francetv/ftv"
+)
+
+// scheduler runs the pages and episodes of a run, up to jobs of them at once.
+// The tasks queued by a running task, the episodes of a page, go before the
+// ones queued earlier: the episodes of a page are downloaded before the next
+// pages are fetched, in order with a single job.
+type scheduler struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []task
+	running int
+	closed  bool
+	// fatal is the error that stopped the run, the queued tasks are dropped.
+	fatal error
+	// schemaErrors counts the last tasks failing with an unexpected API
+	// response.
+	schemaErrors int
+}
+
+type task struct {
+	// title names the page or episode in the run summary.
+	title string
+	run   func() error
+	// page is set for the tasks queuing the episodes of a page, only their
+	// failures are recorded in the run summary.
+	page bool
+}
+
+// sched runs the tasks of the run, started with -jobs workers in main.
+var sched *scheduler
+
+// newScheduler starts the workers running the queued tasks.
+func newScheduler(jobs int) *scheduler {
+	s := &scheduler{}
+	s.cond = sync.NewCond(&s.mu)
+	for i := 0; i < max(jobs, 1); i++ {
+		go s.work()
+	}
+	return s
+}
+
+// add queues the tasks, in order, ahead of the ones already queued. The tasks
+// are dropped once the run stopped.
+func (s *scheduler) add(tasks ...task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fatal != nil || s.closed {
+		return
+	}
+	s.queue = append(tasks, s.queue...)
+	s.cond.Broadcast()
+}
+
+// wait waits for all the tasks to run, stops the workers and returns the
+// fatal error, if any.
+func (s *scheduler) wait() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) > 0 || s.running > 0 {
+		s.cond.Wait()
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	return s.fatal
+}
+
+func (s *scheduler) work() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			return
+		}
+		t := s.queue[0]
+		s.queue = s.queue[1:]
+		s.running++
+
+		s.mu.Unlock()
+		err := t.run()
+		s.mu.Lock()
+
+		s.running--
+		switch {
+		case errors.Is(err, ftv.ErrSchemaChanged):
+			s.schemaErrors++
+		case !t.page:
+			s.schemaErrors = 0
+		}
+		fatal := isFatal(err)
+		if s.schemaErrors >= maxSchemaErrors {
+			fatal = true
+			err = fmt.Errorf("stopping after %d unexpected API responses in a row - %w", s.schemaErrors, err)
+		}
+		if !fatal && (!t.page || err != nil) {
+			summary.add(t.title, err)
+		}
+		if fatal && s.fatal == nil {
+			s.fatal = err
+			s.queue = nil
+		}
+		s.cond.Broadcast()
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/mux"
+)
+
+// runPages queues pages of episodes the way downloadPages does, the episodes
+// being run by episode, and returns the tasks in the order they ran.
+func runPages(t *testing.T, jobs, pages, episodes int, episode func(page, ep int) error) ([]string, error) {
+	t.Helper()
+	summary = runSummary{}
+	sched = newScheduler(jobs)
+	var mu sync.Mutex
+	var order []string
+	ran := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	var tasks []task
+	for p := 0; p < pages; p++ {
+		p := p
+		tasks = append(tasks, task{title: fmt.Sprint("page", p), page: true, run: func() error {
+			ran(fmt.Sprint("page", p))
+			var eps []task
+			for e := 0; e < episodes; e++ {
+				e := e
+				eps = append(eps, task{title: fmt.Sprintf("ep%d%d", p, e), run: func() error {
+					ran(fmt.Sprintf("ep%d%d", p, e))
+					return episode(p, e)
+				}})
+			}
+			sched.add(eps...)
+			return nil
+		}})
+	}
+	sched.add(tasks...)
+	err := sched.wait()
+	return order, err
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	order, err := runPages(t, 1, 2, 2, func(int, int) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the episodes of a page run before the next page
+	expected := []string{"page0", "ep00", "ep01", "page1", "ep10", "ep11"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("got %v, expected %v", order, expected)
+	}
+}
+
+func TestSchedulerSummary(t *testing.T) {
+	_, err := runPages(t, 3, 3, 2, func(p, e int) error {
+		switch {
+		case p == 1 && e == 0:
+			return errSkipped
+		case p == 2 && e == 1:
+			return ftv.ErrGeoBlocked
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pages aren't counted as episodes
+	if summary.done != 4 || summary.skipped != 1 || len(summary.failed) != 1 || summary.failed[0].title != "ep21" {
+		t.Errorf("unexpected summary: %d ok, %d skipped, %v failed", summary.done, summary.skipped, summary.failed)
+	}
+	if code := summary.exitCode(nil); code != exitPartial {
+		t.Errorf("expected the exit code %d, got %d", exitPartial, code)
+	}
+}
+
+func TestSchedulerPageFailure(t *testing.T) {
+	summary = runSummary{}
+	sched = newScheduler(2)
+	sched.add(task{title: "page", page: true, run: func() error { return errors.New("bad status") }})
+	if err := sched.wait(); err != nil {
+		t.Fatal(err)
+	}
+	if summary.done != 0 || len(summary.failed) != 1 || summary.failed[0].title != "page" {
+		t.Errorf("expected the page to be recorded as failed, got %d ok, %v failed", summary.done, summary.failed)
+	}
+}
+
+func TestSchedulerFatal(t *testing.T) {
+	order, err := runPages(t, 1, 3, 2, func(p, e int) error {
+		if p == 1 && e == 0 {
+			return mux.ErrNoFfmpeg
+		}
+		return nil
+	})
+	if !errors.Is(err, mux.ErrNoFfmpeg) {
+		t.Fatalf("expected the fatal error, got %v", err)
+	}
+	// the queued episodes and pages are dropped
+	expected := []string{"page0", "ep00", "ep01", "page1", "ep10"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("got %v, expected %v", order, expected)
+	}
+	if summary.done != 2 || len(summary.failed) != 0 {
+		t.Errorf("expected the fatal error to stay out of the summary, got %d ok, %v failed", summary.done, summary.failed)
+	}
+	// nothing is queued once the run stopped
+	sched.add(task{title: "late", run: func() error {
+		t.Error("a task ran after the run stopped")
+		return nil
+	}})
+}
+
+func TestSchedulerSchemaErrors(t *testing.T) {
+	// a single unexpected API response only fails its episode
+	_, err := runPages(t, 1, 2, 2, func(p, e int) error {
+		if p == 0 && e == 1 {
+			return ftv.ErrSchemaChanged
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.done != 3 || len(summary.failed) != 1 || summary.failed[0].title != "ep01" {
+		t.Errorf("unexpected summary: %d ok, %v failed", summary.done, summary.failed)
+	}
+
+	// the run stops after maxSchemaErrors of them in a row
+	order, err := runPages(t, 1, 3, 2, func(p, e int) error {
+		if p > 0 {
+			return ftv.ErrSchemaChanged
+		}
+		return nil
+	})
+	if !errors.Is(err, ftv.ErrSchemaChanged) {
+		t.Fatalf("expected the fatal error, got %v", err)
+	}
+	expected := []string{"page0", "ep00", "ep01", "page1", "ep10", "ep11", "page2", "ep20"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("got %v, expected %v", order, expected)
+	}
+	if summary.done != 2 || len(summary.failed) != maxSchemaErrors-1 {
+		t.Errorf("unexpected summary: %d ok, %v failed", summary.done, summary.failed)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astisub"
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/manifest"
+	"github.com/mattetti/francetv/mux"
+	"github.com/mattetti/francetv/subtitles"
+)
+
+// subtitles selection, set from -sub-langs and -sub-format in main
+var (
+	subLangs  []string
+	subFormat = "srt"
+)
+
+// writeStreamSubtitles saves the subtitles of the stream picked by
+// -sub-langs next to the video at videoPath, without downloading the video.
+func writeStreamSubtitles(stream *ftv.StreamData, videoPath string) error {
+	m, err := client.Manifest(stream)
+	if err != nil {
+		return err
+	}
+	return writeSubtitles(m.SelectText(subLangs), videoPath, nil)
+}
+
+// writeSubtitles saves the subtitles tracks next to the video at videoPath,
+// in the -sub-format format. When the video was cut, kept are the sections
+// left and the subtitles are moved to the new timeline.
+func writeSubtitles(tracks []*manifest.Track, videoPath string, kept []mux.Range) error {
+	if len(tracks) == 0 {
+		fmt.Println("No subtitles to download for", videoPath)
+		return nil
+	}
+
+	used := map[string]bool{}
+	for _, t := range tracks {
+		subs, err := subtitles.Fetch(client.HTTPClient, t)
+		if err != nil {
+			return fmt.Errorf("failed to download the %s subtitles - %w", t.Lang, err)
+		}
+		if kept != nil {
+			cutSubtitles(subs, kept)
+		}
+
+		path := subtitles.SidecarPath(videoPath, t, subFormat)
+		// several tracks can share the language and kind, number them
+		for i := 2; used[path]; i++ {
+			path = sidecarPath(videoPath, fmt.Sprintf(".%s%d.%s", manifest.NormalizeLang(t.Lang), i, subFormat))
+		}
+		used[path] = true
+		if err := subs.Write(path); err != nil {
+			return fmt.Errorf("failed to write %s - %w", path, err)
+		}
+		fmt.Println("Subtitles saved to", path)
+	}
+	return nil
+}
+
+// cutSubtitles moves the subtitles to the timeline of a video once only the
+// kept sections are left, the ones shown during a removed section are
+// dropped.
+func cutSubtitles(subs *astisub.Subtitles, kept []mux.Range) {
+	items := subs.Items[:0]
+	for _, item := range subs.Items {
+		remapped := mux.RemapChapters([]mux.Chapter{{Start: item.StartAt, End: item.EndAt}}, kept)
+		if len(remapped) == 0 {
+			continue
+		}
+		item.StartAt, item.EndAt = remapped[0].Start, remapped[0].End
+		items = append(items, item)
+	}
+	subs.Items = items
+}
@@ -0,0 +1,219 @@
+// Package subtitles downloads the text tracks of the DASH and HLS streams and
+// converts them between the WebVTT, SRT, TTML and ASS formats.
+package subtitles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/mattetti/francetv/manifest"
+)
+
+// Formats lists the supported formats, by file extension.
+var Formats = []string{"srt", "vtt", "ttml", "ass"}
+
+// ErrUnknownFormat is returned for the subtitles that can't be decoded.
+var ErrUnknownFormat = errors.New("unknown subtitles format")
+
+// ParseFormat validates a format name, "webvtt" and "ssa" are accepted as
+// aliases.
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimPrefix(s, ".")); f {
+	case "srt", "vtt", "ttml", "ass":
+		return f, nil
+	case "webvtt":
+		return "vtt", nil
+	case "ssa":
+		return "ass", nil
+	case "dfxp", "xml":
+		return "ttml", nil
+	}
+	return "", fmt.Errorf("unsupported subtitles format %q, expected one of %s", s, strings.Join(Formats, ", "))
+}
+
+// Fetch downloads the segments of a text track and decodes them.
+func Fetch(client *http.Client, t *manifest.Track) (*astisub.Subtitles, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if err := manifest.LoadSegments(client, t); err != nil {
+		return nil, err
+	}
+	if len(t.Segments) == 0 {
+		return nil, fmt.Errorf("no segments found for the %s subtitles", t.Lang)
+	}
+
+	var init []byte
+	if t.Init != nil {
+		var err error
+		if init, err = get(client, *t.Init); err != nil {
+			return nil, err
+		}
+	}
+
+	subs := astisub.NewSubtitles()
+	// segmented WebVTT tracks are aligned on the MPEG-TS timestamps of the
+	// video, the timeline of the first segment is the start of the video
+	var offset time.Duration
+	for i, segment := range t.Segments {
+		data, err := get(client, segment)
+		if err != nil {
+			return nil, err
+		}
+		if init != nil {
+			data = append(append([]byte{}, init...), data...)
+		}
+		if i == 0 {
+			offset = timestampMapOffset(data)
+		}
+		part, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("subtitles segment %d - %w", i, err)
+		}
+		subs.Merge(part)
+	}
+	if offset > 0 {
+		subs.Add(-offset)
+	}
+	return subs, nil
+}
+
+func get(client *http.Client, s manifest.Segment) ([]byte, error) {
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Range != "" {
+		req.Header.Set("Range", "bytes="+s.Range)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("bad status code fetching %s: %s", s.URL, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// Parse decodes a subtitles file or segment. WebVTT, TTML, SRT, ASS and
+// fragmented MP4 holding TTML samples (stpp) are supported.
+func Parse(data []byte) (*astisub.Subtitles, error) {
+	data = bytes.TrimPrefix(data, astisub.BytesBOM)
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return astisub.NewSubtitles(), nil
+	case bytes.HasPrefix(trimmed, []byte("WEBVTT")):
+		return astisub.ReadFromWebVTT(bytes.NewReader(trimmed))
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return astisub.ReadFromTTML(bytes.NewReader(trimmed))
+	case bytes.HasPrefix(trimmed, []byte("[Script Info]")):
+		return astisub.ReadFromSSA(bytes.NewReader(trimmed))
+	case isMP4(data):
+		return parseMP4(data)
+	case srtStartRegexp.Match(trimmed):
+		return astisub.ReadFromSRT(bytes.NewReader(trimmed))
+	}
+	return nil, ErrUnknownFormat
+}
+
+var srtStartRegexp = regexp.MustCompile(`^\d+\r?\n\d{2}:\d{2}:\d{2},\d{3} --> `)
+
+// isMP4 checks if the data starts with an ISO BMFF box.
+func isMP4(data []byte) bool {
+	if len(data) < 8 {
+		return false
+	}
+	switch string(data[4:8]) {
+	case "ftyp", "styp", "moov", "moof", "sidx", "mdat":
+		return true
+	}
+	return false
+}
+
+// parseMP4 decodes the TTML documents stored in the mdat boxes of
+// fragmented MP4 segments, the wvtt samples aren't supported.
+func parseMP4(data []byte) (*astisub.Subtitles, error) {
+	subs := astisub.NewSubtitles()
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		boxType := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, fmt.Errorf("truncated MP4 box %s", boxType)
+			}
+			size, header = binary.BigEndian.Uint64(data[8:16]), 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated MP4 box %s", boxType)
+		}
+		if boxType == "mdat" {
+			payload := bytes.TrimSpace(data[header:size])
+			if len(payload) > 0 {
+				if !bytes.HasPrefix(payload, []byte("<")) {
+					return nil, fmt.Errorf("%w: only TTML samples are supported in MP4 subtitles", ErrUnknownFormat)
+				}
+				part, err := astisub.ReadFromTTML(bytes.NewReader(payload))
+				if err != nil {
+					return nil, err
+				}
+				subs.Merge(part)
+			}
+		}
+		data = data[size:]
+	}
+	return subs, nil
+}
+
+var timestampMapRegexp = regexp.MustCompile(`X-TIMESTAMP-MAP=.*MPEGTS:(\d+)`)
+
+// timestampMapOffset returns the MPEG-TS time a WebVTT segment is mapped
+// to, if any.
+func timestampMapOffset(data []byte) time.Duration {
+	m := timestampMapRegexp.FindSubmatch(data)
+	if m == nil {
+		return 0
+	}
+	ticks, err := strconv.ParseInt(string(m[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	// 90kHz clock
+	return time.Duration(ticks) * time.Second / 90000
+}
+
+// Convert converts the subtitles file at src to dst, the formats are picked
+// from the extensions.
+func Convert(src, dst string) error {
+	subs, err := astisub.OpenFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s - %w", src, err)
+	}
+	return subs.Write(dst)
+}
+
+// SidecarPath returns the path of the subtitles of the video at videoPath,
+// named so players pick them up: "<video>.<lang>[.sdh].<format>".
+func SidecarPath(videoPath string, t *manifest.Track, format string) string {
+	name := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "." + manifest.NormalizeLang(t.Lang)
+	if t.SDH {
+		name += ".sdh"
+	}
+	return name + "." + format
+}
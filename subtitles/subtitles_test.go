@@ -0,0 +1,144 @@
+package subtitles
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattetti/francetv/manifest"
+)
+
+// box builds an ISO BMFF box.
+func box(boxType string, payload []byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], boxType)
+	return append(b, payload...)
+}
+
+func TestParse(t *testing.T) {
+	ttml, err := os.ReadFile("testdata/sample.ttml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp4 := append(box("styp", []byte("msdh")), box("moof", make([]byte, 16))...)
+	mp4 = append(mp4, box("mdat", ttml)...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"webvtt", []byte("WEBVTT\n\n00:00:01.000 --> 00:00:03.500\nBonjour à tous.\n\n00:00:04.000 --> 00:00:06.000\n(Musique)\n")},
+		{"srt", []byte("1\n00:00:01,000 --> 00:00:03,500\nBonjour à tous.\n\n2\n00:00:04,000 --> 00:00:06,000\n(Musique)\n")},
+		{"ttml", ttml},
+		{"stpp", mp4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs, err := Parse(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(subs.Items) != 2 {
+				t.Fatalf("expected 2 items, got %d", len(subs.Items))
+			}
+			first := subs.Items[0]
+			if first.StartAt != time.Second || first.EndAt != 3500*time.Millisecond || first.String() != "Bonjour à tous." {
+				t.Errorf("unexpected first item %s -> %s %q", first.StartAt, first.EndAt, first.String())
+			}
+		})
+	}
+
+	if _, err := Parse([]byte("not subtitles")); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestFetchSegmentedWebVTT(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0.vtt":
+			fmt.Fprint(w, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n\n00:00:02.000 --> 00:00:04.000\nPremier\n")
+		case "/1.vtt":
+			fmt.Fprint(w, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n\n00:00:12.000 --> 00:00:14.000\nSecond\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	track := &manifest.Track{Kind: manifest.Text, Lang: "fr", Segments: []manifest.Segment{
+		{URL: srv.URL + "/0.vtt"}, {URL: srv.URL + "/1.vtt"},
+	}}
+	subs, err := Fetch(srv.Client(), track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(subs.Items))
+	}
+	// the MPEG-TS offset of the first segment is the start of the video
+	if subs.Items[0].StartAt != 2*time.Second || subs.Items[1].StartAt != 12*time.Second {
+		t.Errorf("unexpected timings %s, %s", subs.Items[0].StartAt, subs.Items[1].StartAt)
+	}
+
+	track.Segments = append(track.Segments, manifest.Segment{URL: srv.URL + "/missing.vtt"})
+	if _, err := Fetch(srv.Client(), track); err == nil {
+		t.Error("expected an error for the missing segment")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "sample.srt")
+	if err := Convert("testdata/sample.ttml", dst); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "00:00:01,000 --> 00:00:03,500\nBonjour à tous.") {
+		t.Errorf("unexpected srt output:\n%s", b)
+	}
+
+	for _, format := range Formats {
+		if err := Convert(dst, filepath.Join(dir, "sample."+format)); err != nil {
+			t.Errorf("failed to convert to %s - %v", format, err)
+		}
+	}
+}
+
+func TestSidecarPath(t *testing.T) {
+	tests := []struct {
+		track    manifest.Track
+		format   string
+		expected string
+	}{
+		{manifest.Track{Lang: "fr"}, "srt", "Show/Episode.fra.srt"},
+		{manifest.Track{Lang: "fr", SDH: true}, "vtt", "Show/Episode.fra.sdh.vtt"},
+		{manifest.Track{}, "ass", "Show/Episode.und.ass"},
+	}
+	for _, tt := range tests {
+		if got := SidecarPath("Show/Episode.mkv", &tt.track, tt.format); got != tt.expected {
+			t.Errorf("got %s, expected %s", got, tt.expected)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, expected := range map[string]string{"SRT": "srt", "webvtt": "vtt", ".ass": "ass", "ssa": "ass", "dfxp": "ttml"} {
+		if got, err := ParseFormat(in); err != nil || got != expected {
+			t.Errorf("%s: got %q %v, expected %q", in, got, err, expected)
+		}
+	}
+	if _, err := ParseFormat("sub"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
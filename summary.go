@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/mux"
+)
+
+// Exit codes, so schedulers can tell a partial failure from a broken run.
+const (
+	exitOK      = 0
+	exitPartial = 1 // some episodes failed, the other ones were downloaded
+	exitFatal   = 2 // the run stopped early or couldn't start
+)
+
+// errSkipped is returned for the episodes left alone on purpose: already
+// downloaded, filtered out...
+var errSkipped = errors.New("skipped")
+
+// maxSchemaErrors is the number of unexpected API responses in a row after
+// which the run is stopped, a single one can be a broken episode.
+const maxSchemaErrors = 3
+
+// isFatal reports whether the error will happen for every episode, the run is
+// stopped then. The unexpected API responses are only fatal after
+// maxSchemaErrors of them in a row, see scheduler.
+func isFatal(err error) bool {
+	return errors.Is(err, mux.ErrNoFfmpeg)
+}
+
+// runSummary collects the outcome of the episodes of a run, the episodes
+// running in parallel share it.
+type runSummary struct {
+	mu      sync.Mutex
+	done    int
+	skipped int
+	failed  []failure
+}
+
+type failure struct {
+	title string
+	err   error
+}
+
+var summary runSummary
+
+// add records the outcome of an episode, printing the error if it failed.
+func (s *runSummary) add(title string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err == nil:
+		s.done++
+	case errors.Is(err, errSkipped):
+		s.skipped++
+	default:
+		fmt.Printf("Failed to download %s\n%v\n", title, err)
+		s.failed = append(s.failed, failure{title: title, err: err})
+	}
+}
+
+// print writes the number of episodes downloaded, skipped and failed, and
+// the reasons of the failures.
+func (s *runSummary) print(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := s.done + s.skipped + len(s.failed)
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%d episode(s): %d ok, %d skipped, %d failed\n", total, s.done, s.skipped, len(s.failed))
+	for _, f := range s.failed {
+		fmt.Fprintf(w, "  %s: %s\n", f.title, failureReason(f.err))
+	}
+}
+
+// failureReason shortens the well known errors to their reason.
+func failureReason(err error) string {
+	for _, reason := range []error{ftv.ErrGeoBlocked, ftv.ErrExpired, ftv.ErrNotYetAvailable, ftv.ErrDRMProtected, ftv.ErrNoStream, ftv.ErrSchemaChanged, mux.ErrNoFfmpeg} {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return err.Error()
+}
+
+// exitCode returns the exit code of the run, fatal being the error that
+// stopped it, if any.
+func (s *runSummary) exitCode(fatal error) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case fatal != nil:
+		return exitFatal
+	case len(s.failed) > 0:
+		return exitPartial
+	}
+	return exitOK
+}
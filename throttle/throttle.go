@@ -0,0 +1,96 @@
+// Package throttle limits the number of simultaneous requests sent to each
+// host, so running several downloads at once doesn't hammer the servers.
+package throttle
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Transport is an http.RoundTripper sending at most PerHost requests at once
+// to each host. A request holds its slot until its response body is read to
+// the end or closed.
+type Transport struct {
+	// Base makes the requests, http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+	// PerHost is the maximum number of requests in flight per host, 0
+	// disables the limit.
+	PerHost int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// NewClient returns a copy of client, http.DefaultClient if nil, sending at
+// most perHost requests at once to each host.
+func NewClient(client *http.Client, perHost int) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &Transport{Base: client.Transport, PerHost: perHost}
+	return &c
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.PerHost <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	slots := t.slots(req.URL.Host)
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { <-slots }) }
+
+	res, err := base.RoundTrip(req)
+	if err != nil || res.Body == nil {
+		release()
+		return res, err
+	}
+	res.Body = &body{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// slots returns the semaphore of the host.
+func (t *Transport) slots(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hosts == nil {
+		t.hosts = map[string]chan struct{}{}
+	}
+	slots, ok := t.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, t.PerHost)
+		t.hosts[host] = slots
+	}
+	return slots
+}
+
+// body releases the slot of its request once read or closed.
+type body struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
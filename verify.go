@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattetti/francetv/ftv"
+	"github.com/mattetti/francetv/mux"
+)
+
+// verifyAttempts is how many times a video failing the verification is
+// downloaded.
+const verifyAttempts = 2
+
+// expectedTracks returns what the video muxed from the tracks should hold.
+func expectedTracks(stream *ftv.StreamData, tracks []mux.Track) mux.Expected {
+	e := mux.Expected{Duration: stream.Length()}
+	for _, t := range tracks {
+		switch t.Kind {
+		case mux.Video:
+			e.Video++
+		case mux.Audio:
+			e.Audio++
+		case mux.Subtitles:
+			e.Subtitles++
+		}
+	}
+	return e
+}
+
+// verifyDownload checks the downloaded video and records what was expected
+// in its archive entry. The verification is skipped, with a warning, when
+// ffprobe isn't installed.
+func verifyDownload(d *completedDownload) error {
+	e := d.expected
+	d.entry.Duration, d.entry.Tolerance = int(e.Duration/time.Second), int(e.Tolerance/time.Second)
+	d.entry.VideoTracks, d.entry.AudioTracks, d.entry.TextTracks = e.Video, e.Audio, e.Subtitles
+	if *skipVerifyFlag {
+		return nil
+	}
+	err := mux.Verify(d.file, e)
+	if errors.Is(err, mux.ErrNoFfprobe) {
+		fmt.Println("Warning:", err)
+		return nil
+	}
+	return err
+}
+
+// verifyExisting checks a video found at the output path before skipping
+// it, a partial file from an interrupted run for instance isn't a download.
+// The duration isn't checked for the videos cut by -strip-intro and
+// -strip-credits.
+func verifyExisting(path string, stream *ftv.StreamData) error {
+	if *skipVerifyFlag {
+		return nil
+	}
+	e := mux.Expected{Video: 1}
+	if !*stripIntroFlag && !*stripCreditsFlag {
+		e.Duration = stream.Length()
+	}
+	err := mux.Verify(path, e)
+	if errors.Is(err, mux.ErrNoFfprobe) || errors.Is(err, mux.ErrNoFfmpeg) {
+		return nil
+	}
+	return err
+}
+
+// processingPath is where the video saved to finalFile is post-processed and
+// verified. It's hidden, so the media servers don't pick it up, and only gets
+// its final name once verified.
+func processingPath(finalFile string) string {
+	dir, name := filepath.Split(finalFile)
+	ext := filepath.Ext(name)
+	return filepath.Join(dir, "."+strings.TrimSuffix(name, ext)+".tmp"+ext)
+}
+
+// quarantine moves a video failing the verification, the download of
+// finalFile, to the .quarantine directory next to it so it can be inspected.
+// Its name gets the time it was moved at.
+func quarantine(path, finalFile string) error {
+	dir := filepath.Join(filepath.Dir(finalFile), ".quarantine")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	ext := filepath.Ext(finalFile)
+	name := strings.TrimSuffix(filepath.Base(finalFile), ext) + time.Now().Format(".20060102-150405") + ext
+	dst := filepath.Join(dir, name)
+	if err := os.Rename(path, dst); err != nil {
+		return fmt.Errorf("failed to quarantine %s - %w", path, err)
+	}
+	fmt.Println("Moved", finalFile, "to", dst)
+	return nil
+}
+
+// verifyLibrary checks the videos recorded in the archive. The missing ones
+// are reported, they may have been moved on purpose, and the ones failing
+// the verification are quarantined and removed from the archive so the next
+// run downloads them again.
+func verifyLibrary() int {
+	if archive == nil {
+		fmt.Println("-verify checks the videos of the download archive, pass it with -archive")
+		return exitFatal
+	}
+	var ok, missing, failed int
+	for _, e := range archive.Entries() {
+		if !fileAlreadyExists(e.Path) {
+			fmt.Printf("MISSING %s (moved or deleted)\n", e.Path)
+			missing++
+			continue
+		}
+		expected := mux.Expected{
+			Duration:  time.Duration(e.Duration) * time.Second,
+			Tolerance: time.Duration(e.Tolerance) * time.Second,
+			Video:     e.VideoTracks,
+			Audio:     e.AudioTracks,
+			Subtitles: e.TextTracks,
+		}
+		err := mux.Verify(e.Path, expected)
+		switch {
+		case err == nil:
+			fmt.Printf("OK      %s\n", e.Path)
+			ok++
+			continue
+		case errors.Is(err, mux.ErrNoFfprobe), errors.Is(err, mux.ErrNoFfmpeg):
+			fmt.Println(err)
+			return exitFatal
+		}
+		fmt.Printf("FAILED  %s\n%v\n", e.Path, err)
+		failed++
+		if err := quarantine(e.Path, e.Path); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if err := archive.Remove(e.VideoID); err != nil {
+			fmt.Println(err)
+		}
+	}
+	fmt.Printf("\n%d video(s): %d ok, %d missing, %d failed\n", ok+missing+failed, ok, missing, failed)
+	if failed > 0 {
+		return exitPartial
+	}
+	return exitOK
+}